@@ -1,11 +1,13 @@
 package main
 
 import (
+	"context"
 	"log"
 
 	"red_packet/backend/internal/config"
 	"red_packet/backend/internal/database"
 	"red_packet/backend/internal/http/router"
+	"red_packet/backend/internal/jobs"
 	"red_packet/backend/internal/service"
 )
 
@@ -21,6 +23,12 @@ func main() {
 	}
 
 	services := service.NewContainer(db, cfg)
+	if n, err := services.Task.DisableUnregisteredTasks(); err != nil {
+		log.Fatalf("check task types failed: %v", err)
+	} else if n > 0 {
+		log.Printf("ERROR disabled %d live tasks with unregistered types, fix them in the admin task list", n)
+	}
+	jobs.Start(context.Background(), services)
 	e := router.New(services, cfg)
 	addr := ":" + cfg.Server.Port
 	if err := e.Start(addr); err != nil {
@@ -8,7 +8,8 @@ import (
 
 type Config struct {
 	Server struct {
-		Port string `mapstructure:"port"`
+		Port           string   `mapstructure:"port"`
+		TrustedProxies []string `mapstructure:"trusted_proxies"` // CIDRs allowed to set X-Forwarded-For
 	} `mapstructure:"server"`
 	MySQL struct {
 		DSN string `mapstructure:"dsn"`
@@ -20,6 +21,24 @@ type Config struct {
 	Admin struct {
 		Key string `mapstructure:"key"`
 	} `mapstructure:"admin"`
+	RateLimit struct {
+		Enabled  bool              `mapstructure:"enabled"`
+		Policies []RateLimitPolicy `mapstructure:"policies"`
+	} `mapstructure:"rate_limit"`
+	GeoIP struct {
+		DBPath string `mapstructure:"db_path"` // CSV: start_ip,end_ip,country
+	} `mapstructure:"geoip"`
+	TaskCallback struct {
+		Secrets map[string]string `mapstructure:"secrets"` // task type -> HMAC secret
+	} `mapstructure:"task_callback"`
+}
+
+type RateLimitPolicy struct {
+	Name          string `mapstructure:"name"`
+	Route         string `mapstructure:"route"` // "POST /api/auth/login" or "*"
+	Key           string `mapstructure:"key"`   // ip/user/device
+	Limit         int    `mapstructure:"limit"`
+	WindowSeconds int    `mapstructure:"window_seconds"`
 }
 
 func Load() (Config, error) {
@@ -36,6 +55,7 @@ func Load() (Config, error) {
 	v.SetDefault("server.port", "8080")
 	v.SetDefault("jwt.ttl_hours", 168)
 	v.SetDefault("admin.key", "change-admin-key")
+	v.SetDefault("rate_limit.enabled", true)
 
 	if err := v.ReadInConfig(); err != nil {
 		// Allow env-only startup.
@@ -1,7 +1,11 @@
 package database
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
+	"strconv"
+	"strings"
 
 	"red_packet/backend/internal/config"
 	"red_packet/backend/internal/models"
@@ -19,39 +23,149 @@ func Init(cfg config.Config) (*gorm.DB, error) {
 		return nil, err
 	}
 
+	if err := migrateUserAccounts(db); err != nil {
+		return nil, err
+	}
+	if m := db.Migrator(); m.HasTable(&models.ReferralCode{}) && m.HasIndex(&models.ReferralCode{}, "idx_referral_codes_user_id") {
+		if err := m.DropIndex(&models.ReferralCode{}, "idx_referral_codes_user_id"); err != nil {
+			return nil, err
+		}
+	}
+	if m := db.Migrator(); m.HasTable(&models.Wallet{}) && m.HasIndex(&models.Wallet{}, "idx_wallets_user_id") {
+		if err := m.DropIndex(&models.Wallet{}, "idx_wallets_user_id"); err != nil {
+			return nil, err
+		}
+	}
+	if m := db.Migrator(); m.HasTable(&models.PayoutAccount{}) && m.HasIndex(&models.PayoutAccount{}, "uniq_payout_account") {
+		if err := m.DropIndex(&models.PayoutAccount{}, "uniq_payout_account"); err != nil {
+			return nil, err
+		}
+	}
+	if m := db.Migrator(); m.HasTable(&models.SpinChance{}) && m.HasIndex(&models.SpinChance{}, "idx_spin_chances_user_id") {
+		if err := m.DropIndex(&models.SpinChance{}, "idx_spin_chances_user_id"); err != nil {
+			return nil, err
+		}
+	}
 	if err := db.AutoMigrate(
 		&models.User{},
 		&models.ReferralCode{},
 		&models.ReferralEdge{},
+		&models.ReferralClick{},
 		&models.Wallet{},
 		&models.WalletLedger{},
 		&models.Reward{},
 		&models.Task{},
 		&models.UserTaskEvent{},
+		&models.TaskCompletion{},
 		&models.WithdrawRequest{},
+		&models.PayoutAccount{},
+		&models.PlatformLedger{},
+		&models.KYCSubmission{},
 		&models.DeviceFingerprint{},
 		&models.RiskFlag{},
+		&models.RiskDecision{},
 		&models.Blacklist{},
 		&models.AppConfig{},
+		&models.Campaign{},
+		&models.FXRate{},
 		&models.SpinChance{},
 		&models.SpinRecord{},
+		&models.IdempotencyRecord{},
+		&models.AssistSession{},
+		&models.AssistHelp{},
+		&models.LeaderboardSettlement{},
 	); err != nil {
 		return nil, err
 	}
 
+	if err := db.Model(&models.ReferralCode{}).
+		Where("active_user_id IS NULL AND retired_at IS NULL").
+		Updates(map[string]interface{}{"active_user_id": gorm.Expr("user_id"), "kind": "legacy"}).Error; err != nil {
+		return nil, err
+	}
+
+	// Account numbers are unique among active accounts only.
+	if err := db.Model(&models.PayoutAccount{}).
+		Where("status = ? AND active_key IS NULL", "active").
+		Update("active_key", gorm.Expr("CONCAT(method, '|', provider, '|', account_no)")).Error; err != nil {
+		return nil, err
+	}
+
 	if err := seed(db); err != nil {
 		return nil, err
 	}
+	if err := migrateCurrencies(db); err != nil {
+		return nil, err
+	}
 	return db, nil
 }
 
+func migrateUserAccounts(db *gorm.DB) error {
+	m := db.Migrator()
+	if !m.HasTable(&models.User{}) {
+		return nil
+	}
+	var conflicts []string
+	for _, column := range []string{"phone", "email"} {
+		if !m.HasColumn(&models.User{}, column) {
+			continue
+		}
+		if err := db.Model(&models.User{}).Where(column+" = ?", "").Update(column, nil).Error; err != nil {
+			return err
+		}
+		if idx := "idx_users_" + column; m.HasIndex(&models.User{}, idx) {
+			if err := m.DropIndex(&models.User{}, idx); err != nil {
+				return err
+			}
+		}
+		type dupRow struct {
+			Value string
+			IDs   string
+		}
+		var rows []dupRow
+		if err := db.Model(&models.User{}).
+			Select(column + " AS value, GROUP_CONCAT(id ORDER BY id) AS ids").
+			Where(column + " IS NOT NULL").
+			Group(column).Having("COUNT(*) > 1").
+			Scan(&rows).Error; err != nil {
+			return err
+		}
+		for _, r := range rows {
+			conflicts = append(conflicts, fmt.Sprintf("%s=%s (users %s)", column, r.Value, r.IDs))
+		}
+	}
+	if len(conflicts) > 0 {
+		return fmt.Errorf("duplicate user accounts block unique phone/email indexes, merge them before starting: %s", strings.Join(conflicts, "; "))
+	}
+	return nil
+}
+
+func migrateCurrencies(db *gorm.DB) error {
+	var cfg models.AppConfig
+	if err := db.Where("`key` = ?", "base_currency").First(&cfg).Error; err != nil {
+		return err
+	}
+	for _, model := range []interface{}{&models.Wallet{}, &models.WalletLedger{}, &models.Reward{}, &models.WithdrawRequest{}, &models.PlatformLedger{}} {
+		if err := db.Model(model).Where("currency = ? OR currency IS NULL", "").Update("currency", cfg.Value).Error; err != nil {
+			return err
+		}
+	}
+	// Pin users to their oldest wallet so legacy balances stay the default
+	// wallet instead of moving users to an empty country-currency wallet.
+	return db.Model(&models.User{}).Where("currency = ? OR currency IS NULL", "").
+		Update("currency", gorm.Expr("(SELECT w.currency FROM wallets w WHERE w.user_id = users.id ORDER BY w.id LIMIT 1)")).Error
+}
+
 func seed(db *gorm.DB) error {
 	defaultTasks := []models.Task{
-		{Type: "checkin", Name: "每日签到", RewardRuleID: "daily_checkin", RewardAmount: 1, Enabled: true, CountryScope: "*"},
-		{Type: "share", Name: "分享活动页", RewardRuleID: "share_landing", RewardAmount: 2, Enabled: true, CountryScope: "*"},
+		{Type: "checkin", Name: "每日签到", I18n: `{"en":{"name":"Daily check-in"}}`, RewardRuleID: "daily_checkin", RewardAmount: 1, Enabled: true, CountryScope: "*"},
+		{Type: "share", Name: "分享活动页", I18n: `{"en":{"name":"Share the campaign page"}}`, RewardRuleID: "share_landing", RewardAmount: 2, Enabled: true, CountryScope: "*"},
 	}
 	for _, t := range defaultTasks {
-		if err := db.Where("reward_rule_id = ?", t.RewardRuleID).FirstOrCreate(&models.Task{}, t).Error; err != nil {
+		if err := db.Where("reward_rule_id = ?", t.RewardRuleID).Attrs(t).FirstOrCreate(&models.Task{}).Error; err != nil {
+			return err
+		}
+		if err := db.Model(&models.Task{}).Where("reward_rule_id = ? AND (i18n = ? OR i18n IS NULL)", t.RewardRuleID, "").Update("i18n", t.I18n).Error; err != nil {
 			return err
 		}
 	}
@@ -59,18 +173,85 @@ func seed(db *gorm.DB) error {
 		Key:   "reward_tiers",
 		Value: `[{"level":1,"target":50,"bonus":8},{"level":2,"target":150,"bonus":20}]`,
 	}
-	if err := db.Where("`key` = ?", bootstrap.Key).FirstOrCreate(&models.AppConfig{}, bootstrap).Error; err != nil {
+	if err := db.Where("`key` = ?", bootstrap.Key).Attrs(bootstrap).FirstOrCreate(&models.AppConfig{}).Error; err != nil {
 		return err
 	}
 	defaultConfigs := []models.AppConfig{
 		{Key: "invite_reward_l1", Value: "3"},
 		{Key: "invite_reward_l2", Value: "1"},
+		{Key: "referral_max_depth", Value: "2"},
+		{Key: "invite_valid_rules", Value: `{"min_distinct_tasks":1}`},
+		{Key: "invite_valid_pending_days", Value: "30"},
+		{Key: "invite_valid_recheck_minutes", Value: "60"},
+		{Key: "invite_milestones", Value: `[{"count":5,"bonus":5},{"count":10,"bonus":12},{"count":50,"bonus":80}]`},
+		{Key: "invite_leaderboard", Value: `{"top_n":20,"daily":{"enabled":true,"prizes":[10,5,3]},"weekly":{"enabled":true,"prizes":[50,30,20]}}`},
 		{Key: "withdraw_min", Value: "60"},
+		{Key: "withdraw_min_by_currency", Value: "{}"},
+		{Key: "base_currency", Value: "USD"},
+		{Key: "country_currencies", Value: `{"ID":"IDR","PH":"PHP","VN":"VND","TH":"THB","MY":"MYR","IN":"INR","BR":"BRL","MX":"MXN"}`},
+		{Key: "withdraw_max_amount", Value: "0"},
+		{Key: "withdraw_daily_count_max", Value: "0"},
+		{Key: "withdraw_weekly_count_max", Value: "0"},
+		{Key: "withdraw_daily_amount_max", Value: "0"},
+		{Key: "withdraw_cooldown_minutes", Value: "0"},
+		{Key: "withdraw_allowed_amounts", Value: "[]"},
+		{Key: "withdraw_first_amount", Value: "0"},
+		{Key: "withdraw_fee_rules", Value: `[{"method":"*","country":"*","flat":0,"percent":0,"min":0,"max":0}]`},
+		{Key: "withdraw_pending_sla_hours", Value: "72"},
+		{Key: "withdraw_approved_sla_hours", Value: "168"},
+		{Key: "payout_account_cooldown_hours", Value: "24"},
+		{Key: "payout_account_max", Value: "3"},
+		{Key: "payout_account_require_verify", Value: "0"},
+		{Key: "risk_thresholds", Value: `{"*":{"review":60,"deny":100}}`},
+		{Key: "risk_flag_half_life_hours", Value: "720"},
+		{Key: "risk_flag_default_ttl_hours", Value: "0"},
+		{Key: "risk_flag_auto_ttl_hours", Value: "720"},
+		{Key: "register_device_max", Value: "3"},
+		{Key: "register_ip_max", Value: "10"},
+		{Key: "register_window_hours", Value: "24"},
+		{Key: "register_limit_mode", Value: "restrict"},
+		{Key: "register_welcome_spins", Value: "100"},
+		{Key: "referral_code_length", Value: "7"},
+		{Key: "referral_code_rotate_cooldown_hours", Value: "24"},
+		{Key: "referral_code_retired_grace_hours", Value: "720"},
+		{Key: "referral_landing_url", Value: "/"},
+		{Key: "referral_attribution_window_hours", Value: "72"},
+		{Key: "referral_attribution_device_fallback", Value: "1"},
+		{Key: "referral_bind_window_hours", Value: "72"},
+		{Key: "referral_bind_before_first_task", Value: "1"},
+		{Key: "referral_bind_require_older_inviter", Value: "1"},
+		{Key: "referral_bind_reject_shared_device", Value: "1"},
+		{Key: "referral_bind_reject_shared_ip", Value: "1"},
+		{Key: "assist_config", Value: `{"enabled":true,"goal":5,"expire_hours":24,"reward_type":"spin","reward_per_help":1,"goal_bonus":3,"sessions_per_day":3,"helper_daily_max":3,"reject_shared_device":true,"reject_shared_ip":true}`},
+		{Key: "task_callback_max_skew_seconds", Value: "600"},
+		{Key: "kyc_requirements", Value: `{"*":{"required":false,"doc_types":["id_card","passport"]}}`},
 	}
 	for _, c := range defaultConfigs {
-		if err := db.Where("`key` = ?", c.Key).FirstOrCreate(&models.AppConfig{}, c).Error; err != nil {
+		if err := db.Where("`key` = ?", c.Key).Attrs(c).FirstOrCreate(&models.AppConfig{}).Error; err != nil {
 			return err
 		}
 	}
-	return nil
+	return seedInviteRewardLevels(db)
+}
+
+// seedInviteRewardLevels writes invite_reward_levels from the current
+// invite_reward_l1/l2 values so deeper levels can be configured in one place
+// without changing what existing levels pay.
+func seedInviteRewardLevels(db *gorm.DB) error {
+	levels := []float64{3, 1}
+	for i, key := range []string{"invite_reward_l1", "invite_reward_l2"} {
+		var row models.AppConfig
+		if err := db.Where("`key` = ?", key).Limit(1).Find(&row).Error; err != nil {
+			return err
+		}
+		if v, err := strconv.ParseFloat(strings.TrimSpace(row.Value), 64); err == nil {
+			levels[i] = v
+		}
+	}
+	value, err := json.Marshal(levels)
+	if err != nil {
+		return err
+	}
+	c := models.AppConfig{Key: "invite_reward_levels", Value: string(value)}
+	return db.Where("`key` = ?", c.Key).Attrs(c).FirstOrCreate(&models.AppConfig{}).Error
 }
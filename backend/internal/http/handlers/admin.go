@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/labstack/echo/v4"
 
@@ -19,6 +20,12 @@ type AdminHandler struct {
 	configSvc   *service.ConfigService
 	riskSvc     *service.RiskService
 	opsSvc      *service.AdminOpsService
+	payoutSvc   *service.PayoutService
+	kycSvc      *service.KYCService
+	referralSvc *service.ReferralService
+	assistSvc   *service.AssistService
+	campaignSvc *service.CampaignService
+	currencySvc *service.CurrencyService
 }
 
 func NewAdminHandler(
@@ -27,6 +34,12 @@ func NewAdminHandler(
 	configSvc *service.ConfigService,
 	riskSvc *service.RiskService,
 	opsSvc *service.AdminOpsService,
+	payoutSvc *service.PayoutService,
+	kycSvc *service.KYCService,
+	referralSvc *service.ReferralService,
+	assistSvc *service.AssistService,
+	campaignSvc *service.CampaignService,
+	currencySvc *service.CurrencyService,
 ) *AdminHandler {
 	return &AdminHandler{
 		withdrawSvc: withdrawSvc,
@@ -34,13 +47,19 @@ func NewAdminHandler(
 		configSvc:   configSvc,
 		riskSvc:     riskSvc,
 		opsSvc:      opsSvc,
+		payoutSvc:   payoutSvc,
+		kycSvc:      kycSvc,
+		referralSvc: referralSvc,
+		assistSvc:   assistSvc,
+		campaignSvc: campaignSvc,
+		currencySvc: currencySvc,
 	}
 }
 
 func (h *AdminHandler) ReviewWithdraw(c echo.Context) error {
 	type req struct {
 		RequestID uint   `json:"request_id"`
-		Status    string `json:"status"` // approved/rejected/paid
+		Status    string `json:"status"` // approved/rejected/paid/expired
 		Note      string `json:"note"`
 	}
 	var body req
@@ -61,6 +80,19 @@ func (h *AdminHandler) ReviewWithdraw(c echo.Context) error {
 	return response.OK(c, data)
 }
 
+func (h *AdminHandler) ExpireWithdraws(c echo.Context) error {
+	now := time.Now()
+	expired, err := h.withdrawSvc.ExpireStale(now)
+	if err != nil {
+		return response.Fail(c, http.StatusInternalServerError, "ADMIN_WITHDRAW_EXPIRE_FAILED", err.Error())
+	}
+	flagged, err := h.withdrawSvc.FlagStaleApproved(now)
+	if err != nil {
+		return response.Fail(c, http.StatusInternalServerError, "ADMIN_WITHDRAW_EXPIRE_FAILED", err.Error())
+	}
+	return response.OK(c, map[string]int{"expired": expired, "sla_flagged": flagged})
+}
+
 func (h *AdminHandler) ListWithdraw(c echo.Context) error {
 	page, _ := strconv.Atoi(c.QueryParam("page"))
 	size, _ := strconv.Atoi(c.QueryParam("size"))
@@ -88,35 +120,132 @@ func (h *AdminHandler) ListTasks(c echo.Context) error {
 	return response.OK(c, map[string]interface{}{"items": items})
 }
 
+func (h *AdminHandler) TaskTypes(c echo.Context) error {
+	return response.OK(c, map[string]interface{}{"items": h.taskSvc.TaskTypes()})
+}
+
 func (h *AdminHandler) SaveTask(c echo.Context) error {
 	var in struct {
-		ID           uint    `json:"id"`
-		Type         string  `json:"type"`
-		Name         string  `json:"name"`
-		RewardRuleID string  `json:"reward_rule_id"`
-		RewardAmount float64 `json:"reward_amount"`
-		Enabled      bool    `json:"enabled"`
-		CountryScope string  `json:"country_scope"`
+		ID            uint    `json:"id"`
+		Type          string  `json:"type"`
+		Name          string  `json:"name"`
+		Description   string  `json:"description"`
+		I18n          string  `json:"i18n"`
+		RewardRuleID  string  `json:"reward_rule_id"`
+		RewardAmount  float64 `json:"reward_amount"`
+		Enabled       bool    `json:"enabled"`
+		CountryScope  string  `json:"country_scope"`
+		CampaignID    uint    `json:"campaign_id"`
+		SortOrder     int     `json:"sort_order"`
+		Prerequisites string  `json:"prerequisites"`
+		UnlockRules   string  `json:"unlock_rules"`
+		VerifyParams  string  `json:"verify_params"`
 	}
 	if err := c.Bind(&in); err != nil {
 		return response.Fail(c, http.StatusBadRequest, "BAD_REQUEST", "invalid request")
 	}
 	taskInput := models.Task{
-		ID:           in.ID,
-		Type:         in.Type,
-		Name:         in.Name,
-		RewardRuleID: in.RewardRuleID,
-		RewardAmount: in.RewardAmount,
-		Enabled:      in.Enabled,
-		CountryScope: in.CountryScope,
+		ID:            in.ID,
+		CampaignID:    in.CampaignID,
+		SortOrder:     in.SortOrder,
+		Prerequisites: in.Prerequisites,
+		UnlockRules:   in.UnlockRules,
+		VerifyParams:  in.VerifyParams,
+		Type:          in.Type,
+		Name:          in.Name,
+		Description:   in.Description,
+		I18n:          in.I18n,
+		RewardRuleID:  in.RewardRuleID,
+		RewardAmount:  in.RewardAmount,
+		Enabled:       in.Enabled,
+		CountryScope:  in.CountryScope,
 	}
 	task, err := h.taskSvc.SaveTask(taskInput)
 	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrCampaignNotFound):
+			return response.Fail(c, http.StatusNotFound, "CAMPAIGN_NOT_FOUND", err.Error())
+		case errors.Is(err, service.ErrTaskInvalid):
+			return response.Fail(c, http.StatusBadRequest, "TASK_INVALID", err.Error())
+		case errors.Is(err, service.ErrTaskTypeUnknown):
+			return response.Fail(c, http.StatusBadRequest, "TASK_TYPE_UNKNOWN", err.Error())
+		case errors.Is(err, service.ErrTaskPrerequisiteCycle):
+			return response.Fail(c, http.StatusBadRequest, "TASK_PREREQUISITE_CYCLE", err.Error())
+		}
 		return response.Fail(c, http.StatusInternalServerError, "ADMIN_TASK_SAVE_FAILED", err.Error())
 	}
 	return response.OK(c, task)
 }
 
+func (h *AdminHandler) ListCampaigns(c echo.Context) error {
+	items, err := h.campaignSvc.List()
+	if err != nil {
+		return response.Fail(c, http.StatusInternalServerError, "ADMIN_CAMPAIGN_LIST_FAILED", err.Error())
+	}
+	return response.OK(c, map[string]interface{}{"items": items})
+}
+
+func (h *AdminHandler) SaveCampaign(c echo.Context) error {
+	var in models.Campaign
+	if err := c.Bind(&in); err != nil {
+		return response.Fail(c, http.StatusBadRequest, "BAD_REQUEST", "invalid request")
+	}
+	campaign, err := h.campaignSvc.Save(in)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrCampaignInvalid):
+			return response.Fail(c, http.StatusBadRequest, "CAMPAIGN_INVALID", err.Error())
+		case errors.Is(err, service.ErrCampaignNotFound):
+			return response.Fail(c, http.StatusNotFound, "CAMPAIGN_NOT_FOUND", err.Error())
+		default:
+			return response.Fail(c, http.StatusInternalServerError, "ADMIN_CAMPAIGN_SAVE_FAILED", err.Error())
+		}
+	}
+	return response.OK(c, campaign)
+}
+
+func (h *AdminHandler) ListFXRates(c echo.Context) error {
+	items, err := h.currencySvc.ListRates()
+	if err != nil {
+		return response.Fail(c, http.StatusInternalServerError, "ADMIN_FX_LIST_FAILED", err.Error())
+	}
+	return response.OK(c, map[string]interface{}{"items": items})
+}
+
+func (h *AdminHandler) UpsertFXRate(c echo.Context) error {
+	var in struct {
+		Currency string  `json:"currency"`
+		Rate     float64 `json:"rate"`
+	}
+	if err := c.Bind(&in); err != nil {
+		return response.Fail(c, http.StatusBadRequest, "BAD_REQUEST", "invalid request")
+	}
+	item, err := h.currencySvc.UpsertRate(in.Currency, in.Rate)
+	if err != nil {
+		if errors.Is(err, service.ErrFXRateInvalid) {
+			return response.Fail(c, http.StatusBadRequest, "FX_RATE_INVALID", err.Error())
+		}
+		return response.Fail(c, http.StatusInternalServerError, "ADMIN_FX_UPSERT_FAILED", err.Error())
+	}
+	return response.OK(c, item)
+}
+
+func (h *AdminHandler) SpendReport(c echo.Context) error {
+	to := time.Now()
+	from := to.AddDate(0, 0, -30)
+	if v, err := time.Parse("2006-01-02", c.QueryParam("from")); err == nil {
+		from = v
+	}
+	if v, err := time.Parse("2006-01-02", c.QueryParam("to")); err == nil {
+		to = v.AddDate(0, 0, 1)
+	}
+	data, err := h.currencySvc.SpendReport(from, to)
+	if err != nil {
+		return response.Fail(c, http.StatusInternalServerError, "ADMIN_SPEND_REPORT_FAILED", err.Error())
+	}
+	return response.OK(c, data)
+}
+
 func (h *AdminHandler) DeleteTask(c echo.Context) error {
 	id, _ := strconv.Atoi(c.Param("id"))
 	if id <= 0 {
@@ -155,7 +284,8 @@ func (h *AdminHandler) ListRiskFlags(c echo.Context) error {
 	page, _ := strconv.Atoi(c.QueryParam("page"))
 	size, _ := strconv.Atoi(c.QueryParam("size"))
 	userID, _ := strconv.Atoi(c.QueryParam("user_id"))
-	items, err := h.riskSvc.ListFlags(uint(userID), page, size)
+	status := strings.TrimSpace(c.QueryParam("status"))
+	items, err := h.riskSvc.ListFlags(uint(userID), status, page, size)
 	if err != nil {
 		return response.Fail(c, http.StatusInternalServerError, "ADMIN_RISK_FLAG_LIST_FAILED", err.Error())
 	}
@@ -164,9 +294,10 @@ func (h *AdminHandler) ListRiskFlags(c echo.Context) error {
 
 func (h *AdminHandler) AddRiskFlag(c echo.Context) error {
 	var in struct {
-		UserID uint   `json:"user_id"`
-		Reason string `json:"reason"`
-		Score  int    `json:"score"`
+		UserID      uint    `json:"user_id"`
+		Reason      string  `json:"reason"`
+		Score       int     `json:"score"`
+		ExpireHours float64 `json:"expire_hours"`
 	}
 	if err := c.Bind(&in); err != nil || in.UserID == 0 {
 		return response.Fail(c, http.StatusBadRequest, "BAD_REQUEST", "invalid request")
@@ -174,13 +305,90 @@ func (h *AdminHandler) AddRiskFlag(c echo.Context) error {
 	if strings.TrimSpace(in.Reason) == "" {
 		in.Reason = "manual admin flag"
 	}
-	item, err := h.riskSvc.AddFlag(in.UserID, in.Reason, in.Score)
+	item, err := h.riskSvc.AddFlag(in.UserID, in.Reason, in.Score, in.ExpireHours)
 	if err != nil {
 		return response.Fail(c, http.StatusInternalServerError, "ADMIN_RISK_FLAG_ADD_FAILED", err.Error())
 	}
 	return response.OK(c, item)
 }
 
+func (h *AdminHandler) UpdateRiskFlag(c echo.Context) error {
+	var in struct {
+		FlagID uint   `json:"flag_id"`
+		Action string `json:"action"` // resolve/appeal/reinstate
+		Note   string `json:"note"`
+	}
+	if err := c.Bind(&in); err != nil || in.FlagID == 0 {
+		return response.Fail(c, http.StatusBadRequest, "BAD_REQUEST", "flag_id is required")
+	}
+	note := strings.TrimSpace(in.Note)
+	var (
+		item models.RiskFlag
+		err  error
+	)
+	switch strings.TrimSpace(in.Action) {
+	case "resolve":
+		item, err = h.riskSvc.ResolveFlag(in.FlagID, note)
+	case "appeal":
+		item, err = h.riskSvc.AppealFlag(in.FlagID, note)
+	case "reinstate":
+		item, err = h.riskSvc.ReinstateFlag(in.FlagID, note)
+	default:
+		return response.Fail(c, http.StatusBadRequest, "BAD_REQUEST", "action must be resolve/appeal/reinstate")
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrRiskFlagNotFound):
+			return response.Fail(c, http.StatusNotFound, "RISK_FLAG_NOT_FOUND", err.Error())
+		case errors.Is(err, service.ErrRiskFlagState):
+			return response.Fail(c, http.StatusBadRequest, "RISK_FLAG_STATE_INVALID", err.Error())
+		default:
+			return response.Fail(c, http.StatusInternalServerError, "ADMIN_RISK_FLAG_UPDATE_FAILED", err.Error())
+		}
+	}
+	return response.OK(c, item)
+}
+
+func (h *AdminHandler) RiskScore(c echo.Context) error {
+	userID, _ := strconv.Atoi(c.QueryParam("user_id"))
+	if userID <= 0 {
+		return response.Fail(c, http.StatusBadRequest, "BAD_REQUEST", "user_id is required")
+	}
+	score, err := h.riskSvc.ActiveFlagScore(uint(userID))
+	if err != nil {
+		return response.Fail(c, http.StatusInternalServerError, "ADMIN_RISK_SCORE_FAILED", err.Error())
+	}
+	return response.OK(c, map[string]int{"score": score})
+}
+
+func (h *AdminHandler) ListRiskRules(c echo.Context) error {
+	return response.OK(c, map[string]interface{}{"items": h.riskSvc.Engine().Rules()})
+}
+
+func (h *AdminHandler) ListRiskDecisions(c echo.Context) error {
+	page, _ := strconv.Atoi(c.QueryParam("page"))
+	size, _ := strconv.Atoi(c.QueryParam("size"))
+	userID, _ := strconv.Atoi(c.QueryParam("user_id"))
+	action := strings.TrimSpace(c.QueryParam("action"))
+	items, err := h.riskSvc.ListDecisions(uint(userID), action, page, size)
+	if err != nil {
+		return response.Fail(c, http.StatusInternalServerError, "ADMIN_RISK_DECISION_LIST_FAILED", err.Error())
+	}
+	return response.OK(c, map[string]interface{}{"items": items})
+}
+
+func (h *AdminHandler) ListRiskDetectors(c echo.Context) error {
+	return response.OK(c, map[string]interface{}{"items": h.riskSvc.DetectorConfigs()})
+}
+
+func (h *AdminHandler) RunRiskDetectors(c echo.Context) error {
+	count, err := h.riskSvc.RunDetectors(time.Now())
+	if err != nil {
+		return response.Fail(c, http.StatusInternalServerError, "ADMIN_RISK_DETECT_FAILED", err.Error())
+	}
+	return response.OK(c, map[string]int{"flagged": count})
+}
+
 func (h *AdminHandler) ListBlacklists(c echo.Context) error {
 	page, _ := strconv.Atoi(c.QueryParam("page"))
 	size, _ := strconv.Atoi(c.QueryParam("size"))
@@ -206,3 +414,151 @@ func (h *AdminHandler) AddBlacklist(c echo.Context) error {
 	}
 	return response.OK(c, item)
 }
+
+func (h *AdminHandler) ListPayoutAccounts(c echo.Context) error {
+	page, _ := strconv.Atoi(c.QueryParam("page"))
+	size, _ := strconv.Atoi(c.QueryParam("size"))
+	userID, _ := strconv.Atoi(c.QueryParam("user_id"))
+	items, err := h.payoutSvc.ListAll(uint(userID), page, size)
+	if err != nil {
+		return response.Fail(c, http.StatusInternalServerError, "ADMIN_PAYOUT_LIST_FAILED", err.Error())
+	}
+	return response.OK(c, map[string]interface{}{"items": items})
+}
+
+func (h *AdminHandler) VerifyPayoutAccount(c echo.Context) error {
+	var in struct {
+		AccountID uint `json:"account_id"`
+	}
+	if err := c.Bind(&in); err != nil || in.AccountID == 0 {
+		return response.Fail(c, http.StatusBadRequest, "BAD_REQUEST", "account_id is required")
+	}
+	item, err := h.payoutSvc.Verify(in.AccountID)
+	if err != nil {
+		if errors.Is(err, service.ErrPayoutAccountNotFound) {
+			return response.Fail(c, http.StatusNotFound, "PAYOUT_ACCOUNT_NOT_FOUND", err.Error())
+		}
+		return response.Fail(c, http.StatusInternalServerError, "ADMIN_PAYOUT_VERIFY_FAILED", err.Error())
+	}
+	return response.OK(c, item)
+}
+
+func (h *AdminHandler) ListKYC(c echo.Context) error {
+	page, _ := strconv.Atoi(c.QueryParam("page"))
+	size, _ := strconv.Atoi(c.QueryParam("size"))
+	status := strings.TrimSpace(c.QueryParam("status"))
+	items, err := h.kycSvc.ListSubmissions(status, page, size)
+	if err != nil {
+		return response.Fail(c, http.StatusInternalServerError, "ADMIN_KYC_LIST_FAILED", err.Error())
+	}
+	return response.OK(c, map[string]interface{}{"items": items})
+}
+
+func (h *AdminHandler) ReviewKYC(c echo.Context) error {
+	var in struct {
+		SubmissionID uint   `json:"submission_id"`
+		Status       string `json:"status"` // verified/rejected
+		Note         string `json:"note"`
+	}
+	if err := c.Bind(&in); err != nil || in.SubmissionID == 0 {
+		return response.Fail(c, http.StatusBadRequest, "BAD_REQUEST", "submission_id is required")
+	}
+	item, err := h.kycSvc.Review(in.SubmissionID, strings.TrimSpace(in.Status), strings.TrimSpace(in.Note))
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrKYCNotFound):
+			return response.Fail(c, http.StatusNotFound, "KYC_NOT_FOUND", err.Error())
+		case errors.Is(err, service.ErrKYCState):
+			return response.Fail(c, http.StatusBadRequest, "KYC_STATE_INVALID", err.Error())
+		default:
+			return response.Fail(c, http.StatusInternalServerError, "ADMIN_KYC_REVIEW_FAILED", err.Error())
+		}
+	}
+	return response.OK(c, item)
+}
+
+func (h *AdminHandler) RotateReferralCode(c echo.Context) error {
+	var in struct {
+		UserID uint `json:"user_id"`
+	}
+	if err := c.Bind(&in); err != nil || in.UserID == 0 {
+		return response.Fail(c, http.StatusBadRequest, "BAD_REQUEST", "user_id is required")
+	}
+	item, err := h.referralSvc.AdminRotateCode(in.UserID)
+	if err != nil {
+		return response.Fail(c, http.StatusInternalServerError, "ADMIN_REFERRAL_CODE_ROTATE_FAILED", err.Error())
+	}
+	return response.OK(c, item)
+}
+
+func (h *AdminHandler) SetVanityReferralCode(c echo.Context) error {
+	var in struct {
+		UserID uint   `json:"user_id"`
+		Code   string `json:"code"`
+	}
+	if err := c.Bind(&in); err != nil || in.UserID == 0 || strings.TrimSpace(in.Code) == "" {
+		return response.Fail(c, http.StatusBadRequest, "BAD_REQUEST", "user_id and code are required")
+	}
+	item, err := h.referralSvc.SetVanityCode(in.UserID, in.Code)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrReferralCodeFormat):
+			return response.Fail(c, http.StatusBadRequest, "REFERRAL_CODE_FORMAT", err.Error())
+		case errors.Is(err, service.ErrReferralCodeTaken):
+			return response.Fail(c, http.StatusConflict, "REFERRAL_CODE_TAKEN", err.Error())
+		case errors.Is(err, service.ErrUserNotFound):
+			return response.Fail(c, http.StatusNotFound, "USER_NOT_FOUND", err.Error())
+		default:
+			return response.Fail(c, http.StatusInternalServerError, "ADMIN_REFERRAL_VANITY_FAILED", err.Error())
+		}
+	}
+	return response.OK(c, item)
+}
+
+func (h *AdminHandler) RotateLegacyReferralCodes(c echo.Context) error {
+	var in struct {
+		Limit int `json:"limit"`
+	}
+	_ = c.Bind(&in)
+	rotated, err := h.referralSvc.RotateLegacyCodes(in.Limit)
+	if err != nil {
+		return response.Fail(c, http.StatusInternalServerError, "ADMIN_REFERRAL_CODE_ROTATE_FAILED", err.Error())
+	}
+	return response.OK(c, map[string]int{"rotated": rotated})
+}
+
+func (h *AdminHandler) ReferralClickStats(c echo.Context) error {
+	days, _ := strconv.Atoi(c.QueryParam("days"))
+	if uid, _ := strconv.ParseUint(c.QueryParam("user_id"), 10, 64); uid > 0 {
+		data, err := h.referralSvc.ClickStats(uint(uid), days)
+		if err != nil {
+			return response.Fail(c, http.StatusInternalServerError, "ADMIN_REFERRAL_CLICKS_FAILED", err.Error())
+		}
+		return response.OK(c, data)
+	}
+	limit, _ := strconv.Atoi(c.QueryParam("limit"))
+	items, err := h.referralSvc.TopClickStats(days, limit)
+	if err != nil {
+		return response.Fail(c, http.StatusInternalServerError, "ADMIN_REFERRAL_CLICKS_FAILED", err.Error())
+	}
+	return response.OK(c, map[string]interface{}{"items": items})
+}
+
+func (h *AdminHandler) SettleLeaderboards(c echo.Context) error {
+	paid, err := h.referralSvc.SettleLeaderboards(time.Now())
+	if err != nil {
+		return response.Fail(c, http.StatusInternalServerError, "ADMIN_LEADERBOARD_SETTLE_FAILED", err.Error())
+	}
+	return response.OK(c, map[string]int{"paid": paid})
+}
+
+func (h *AdminHandler) ListAssistSessions(c echo.Context) error {
+	page, _ := strconv.Atoi(c.QueryParam("page"))
+	size, _ := strconv.Atoi(c.QueryParam("size"))
+	uid, _ := strconv.ParseUint(c.QueryParam("user_id"), 10, 64)
+	items, err := h.assistSvc.ListSessions(uint(uid), strings.TrimSpace(c.QueryParam("status")), page, size)
+	if err != nil {
+		return response.Fail(c, http.StatusInternalServerError, "ADMIN_ASSIST_LIST_FAILED", err.Error())
+	}
+	return response.OK(c, map[string]interface{}{"items": items, "config": h.assistSvc.Config()})
+}
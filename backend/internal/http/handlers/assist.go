@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"red_packet/backend/internal/http/response"
+	"red_packet/backend/internal/service"
+)
+
+type AssistHandler struct {
+	svc *service.AssistService
+}
+
+func NewAssistHandler(svc *service.AssistService) *AssistHandler {
+	return &AssistHandler{svc: svc}
+}
+
+func (h *AssistHandler) Open(c echo.Context) error {
+	data, err := h.svc.Open(userID(c))
+	if err != nil {
+		return assistFail(c, err, "ASSIST_OPEN_FAILED")
+	}
+	return response.OK(c, data)
+}
+
+func (h *AssistHandler) Current(c echo.Context) error {
+	data, err := h.svc.Current(userID(c))
+	if err != nil {
+		return assistFail(c, err, "ASSIST_CURRENT_FAILED")
+	}
+	return response.OK(c, data)
+}
+
+func (h *AssistHandler) Get(c echo.Context) error {
+	data, err := h.svc.Get(c.Param("token"))
+	if err != nil {
+		return assistFail(c, err, "ASSIST_GET_FAILED")
+	}
+	return response.OK(c, data)
+}
+
+func (h *AssistHandler) Help(c echo.Context) error {
+	data, err := h.svc.Help(userID(c), c.Param("token"), requestMeta(c))
+	if err != nil {
+		return assistFail(c, err, "ASSIST_HELP_FAILED")
+	}
+	return response.OK(c, data)
+}
+
+func assistFail(c echo.Context, err error, fallback string) error {
+	switch {
+	case errors.Is(err, service.ErrAssistDisabled):
+		return response.Fail(c, http.StatusForbidden, "ASSIST_DISABLED", err.Error())
+	case errors.Is(err, service.ErrAssistNotFound):
+		return response.Fail(c, http.StatusNotFound, "ASSIST_NOT_FOUND", err.Error())
+	case errors.Is(err, service.ErrAssistClosed):
+		return response.Fail(c, http.StatusConflict, "ASSIST_CLOSED", err.Error())
+	case errors.Is(err, service.ErrAssistSelf):
+		return response.Fail(c, http.StatusBadRequest, "ASSIST_SELF", err.Error())
+	case errors.Is(err, service.ErrAssistAlreadyHelped):
+		return response.Fail(c, http.StatusConflict, "ASSIST_ALREADY_HELPED", err.Error())
+	case errors.Is(err, service.ErrAssistHelperLimit):
+		return response.Fail(c, http.StatusTooManyRequests, "ASSIST_HELPER_LIMIT", err.Error())
+	case errors.Is(err, service.ErrAssistSessionLimit):
+		return response.Fail(c, http.StatusTooManyRequests, "ASSIST_SESSION_LIMIT", err.Error())
+	case errors.Is(err, service.ErrAssistSharedEnv):
+		return response.Fail(c, http.StatusForbidden, "ASSIST_SHARED_ENV", err.Error())
+	case errors.Is(err, service.ErrAssistRestricted):
+		return response.Fail(c, http.StatusForbidden, "ASSIST_RESTRICTED", err.Error())
+	case errors.Is(err, service.ErrRiskCheckFailed):
+		return response.Fail(c, http.StatusForbidden, "RISK_CHECK_FAILED", err.Error())
+	default:
+		return response.Fail(c, http.StatusInternalServerError, fallback, err.Error())
+	}
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/labstack/echo/v4"
@@ -22,8 +23,25 @@ func (h *AuthHandler) Login(c echo.Context) error {
 	if err := c.Bind(&req); err != nil {
 		return response.Fail(c, http.StatusBadRequest, "BAD_REQUEST", "invalid request")
 	}
-	token, user, err := h.svc.Login(req)
+	if req.AttributionToken == "" {
+		if cookie, err := c.Cookie(attributionCookie); err == nil {
+			req.AttributionToken = cookie.Value
+		}
+	}
+	token, user, err := h.svc.Login(req, requestMeta(c))
 	if err != nil {
+		if errors.Is(err, service.ErrRiskCheckFailed) {
+			return response.Fail(c, http.StatusForbidden, "RISK_CHECK_FAILED", err.Error())
+		}
+		if errors.Is(err, service.ErrAccountRequired) {
+			return response.Fail(c, http.StatusBadRequest, "ACCOUNT_REQUIRED", err.Error())
+		}
+		if errors.Is(err, service.ErrAccountTaken) {
+			return response.Fail(c, http.StatusConflict, "ACCOUNT_TAKEN", err.Error())
+		}
+		if errors.Is(err, service.ErrRegisterLimited) {
+			return response.Fail(c, http.StatusTooManyRequests, "REGISTER_LIMITED", err.Error())
+		}
 		return response.Fail(c, http.StatusInternalServerError, "AUTH_LOGIN_FAILED", err.Error())
 	}
 	return response.OK(c, map[string]interface{}{
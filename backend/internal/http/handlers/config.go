@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"net/http"
+	"strings"
 
 	"github.com/labstack/echo/v4"
 
@@ -18,7 +19,7 @@ func NewConfigHandler(svc *service.ConfigService) *ConfigHandler {
 }
 
 func (h *ConfigHandler) Bootstrap(c echo.Context) error {
-	data, err := h.svc.Bootstrap()
+	data, err := h.svc.Bootstrap(strings.TrimSpace(c.QueryParam("country")))
 	if err != nil {
 		return response.Fail(c, http.StatusInternalServerError, "CONFIG_BOOTSTRAP_FAILED", err.Error())
 	}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"red_packet/backend/internal/http/response"
+	"red_packet/backend/internal/service"
+)
+
+type KYCHandler struct {
+	svc *service.KYCService
+}
+
+func NewKYCHandler(svc *service.KYCService) *KYCHandler {
+	return &KYCHandler{svc: svc}
+}
+
+func (h *KYCHandler) Status(c echo.Context) error {
+	data, err := h.svc.Status(userID(c))
+	if err != nil {
+		return response.Fail(c, http.StatusInternalServerError, "KYC_STATUS_FAILED", err.Error())
+	}
+	return response.OK(c, data)
+}
+
+func (h *KYCHandler) Submit(c echo.Context) error {
+	var req service.KYCInput
+	if err := c.Bind(&req); err != nil {
+		return response.Fail(c, http.StatusBadRequest, "BAD_REQUEST", "invalid request")
+	}
+	data, err := h.svc.Submit(userID(c), req)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrKYCInvalid):
+			return response.Fail(c, http.StatusBadRequest, "KYC_INVALID", err.Error())
+		case errors.Is(err, service.ErrKYCDocUnsupported):
+			return response.Fail(c, http.StatusBadRequest, "KYC_DOC_UNSUPPORTED", err.Error())
+		case errors.Is(err, service.ErrKYCState):
+			return response.Fail(c, http.StatusConflict, "KYC_STATE_INVALID", err.Error())
+		default:
+			return response.Fail(c, http.StatusInternalServerError, "KYC_SUBMIT_FAILED", err.Error())
+		}
+	}
+	return response.OK(c, data)
+}
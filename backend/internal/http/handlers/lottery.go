@@ -20,19 +20,42 @@ func NewLotteryHandler(svc *service.LotteryService) *LotteryHandler {
 }
 
 func (h *LotteryHandler) Status(c echo.Context) error {
-	status, err := h.svc.GetStatus(userID(c))
+	campaignID, _ := strconv.ParseUint(c.QueryParam("campaign_id"), 10, 64)
+	status, err := h.svc.GetStatus(userID(c), uint(campaignID), requestMeta(c))
 	if err != nil {
+		if errors.Is(err, service.ErrCampaignNotFound) {
+			return response.Fail(c, http.StatusNotFound, "CAMPAIGN_NOT_FOUND", err.Error())
+		}
+		if errors.Is(err, service.ErrCampaignOutOfScope) {
+			return response.Fail(c, http.StatusForbidden, "CAMPAIGN_OUT_OF_SCOPE", err.Error())
+		}
 		return response.Fail(c, http.StatusInternalServerError, "LOTTERY_STATUS_FAILED", err.Error())
 	}
 	return response.OK(c, status)
 }
 
 func (h *LotteryHandler) Spin(c echo.Context) error {
-	result, err := h.svc.Spin(userID(c))
+	var in struct {
+		CampaignID uint `json:"campaign_id"`
+	}
+	_ = c.Bind(&in)
+	result, err := h.svc.Spin(userID(c), in.CampaignID, requestMeta(c))
 	if err != nil {
+		if errors.Is(err, service.ErrCampaignNotFound) {
+			return response.Fail(c, http.StatusNotFound, "CAMPAIGN_NOT_FOUND", err.Error())
+		}
+		if errors.Is(err, service.ErrCampaignInactive) {
+			return response.Fail(c, http.StatusBadRequest, "CAMPAIGN_INACTIVE", err.Error())
+		}
+		if errors.Is(err, service.ErrCampaignOutOfScope) {
+			return response.Fail(c, http.StatusForbidden, "CAMPAIGN_OUT_OF_SCOPE", err.Error())
+		}
 		if errors.Is(err, service.ErrNoSpinChance) {
 			return response.Fail(c, http.StatusBadRequest, "NO_SPIN_CHANCE", err.Error())
 		}
+		if errors.Is(err, service.ErrRiskCheckFailed) {
+			return response.Fail(c, http.StatusBadRequest, "RISK_CHECK_FAILED", err.Error())
+		}
 		return response.Fail(c, http.StatusInternalServerError, "LOTTERY_SPIN_FAILED", err.Error())
 	}
 	return response.OK(c, result)
@@ -41,7 +64,8 @@ func (h *LotteryHandler) Spin(c echo.Context) error {
 func (h *LotteryHandler) Records(c echo.Context) error {
 	page, _ := strconv.Atoi(c.QueryParam("page"))
 	size, _ := strconv.Atoi(c.QueryParam("size"))
-	records, err := h.svc.ListRecords(userID(c), page, size)
+	campaignID, _ := strconv.ParseUint(c.QueryParam("campaign_id"), 10, 64)
+	records, err := h.svc.ListRecords(userID(c), uint(campaignID), page, size)
 	if err != nil {
 		return response.Fail(c, http.StatusInternalServerError, "LOTTERY_RECORDS_FAILED", err.Error())
 	}
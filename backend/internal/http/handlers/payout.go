@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"red_packet/backend/internal/http/response"
+	"red_packet/backend/internal/service"
+)
+
+type PayoutHandler struct {
+	svc *service.PayoutService
+}
+
+func NewPayoutHandler(svc *service.PayoutService) *PayoutHandler {
+	return &PayoutHandler{svc: svc}
+}
+
+func (h *PayoutHandler) List(c echo.Context) error {
+	items, err := h.svc.List(userID(c))
+	if err != nil {
+		return response.Fail(c, http.StatusInternalServerError, "PAYOUT_ACCOUNT_LIST_FAILED", err.Error())
+	}
+	return response.OK(c, map[string]interface{}{"items": items})
+}
+
+func (h *PayoutHandler) Bind(c echo.Context) error {
+	var req service.PayoutAccountInput
+	if err := c.Bind(&req); err != nil {
+		return response.Fail(c, http.StatusBadRequest, "BAD_REQUEST", "invalid request")
+	}
+	data, err := h.svc.Bind(userID(c), req)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrPayoutAccountInvalid):
+			return response.Fail(c, http.StatusBadRequest, "PAYOUT_ACCOUNT_INVALID", err.Error())
+		case errors.Is(err, service.ErrPayoutAccountTaken):
+			return response.Fail(c, http.StatusConflict, "PAYOUT_ACCOUNT_TAKEN", err.Error())
+		case errors.Is(err, service.ErrPayoutAccountLimit):
+			return response.Fail(c, http.StatusBadRequest, "PAYOUT_ACCOUNT_LIMIT", err.Error())
+		default:
+			return response.Fail(c, http.StatusInternalServerError, "PAYOUT_ACCOUNT_BIND_FAILED", err.Error())
+		}
+	}
+	return response.OK(c, data)
+}
+
+func (h *PayoutHandler) Remove(c echo.Context) error {
+	type req struct {
+		AccountID uint `json:"account_id"`
+	}
+	var body req
+	if err := c.Bind(&body); err != nil || body.AccountID == 0 {
+		return response.Fail(c, http.StatusBadRequest, "BAD_REQUEST", "account_id is required")
+	}
+	if err := h.svc.Remove(userID(c), body.AccountID); err != nil {
+		if errors.Is(err, service.ErrPayoutAccountNotFound) {
+			return response.Fail(c, http.StatusNotFound, "PAYOUT_ACCOUNT_NOT_FOUND", err.Error())
+		}
+		return response.Fail(c, http.StatusInternalServerError, "PAYOUT_ACCOUNT_REMOVE_FAILED", err.Error())
+	}
+	return response.OK(c, map[string]bool{"removed": true})
+}
+
+func (h *PayoutHandler) SetDefault(c echo.Context) error {
+	type req struct {
+		AccountID uint `json:"account_id"`
+	}
+	var body req
+	if err := c.Bind(&body); err != nil || body.AccountID == 0 {
+		return response.Fail(c, http.StatusBadRequest, "BAD_REQUEST", "account_id is required")
+	}
+	if err := h.svc.SetDefault(userID(c), body.AccountID); err != nil {
+		if errors.Is(err, service.ErrPayoutAccountNotFound) {
+			return response.Fail(c, http.StatusNotFound, "PAYOUT_ACCOUNT_NOT_FOUND", err.Error())
+		}
+		return response.Fail(c, http.StatusInternalServerError, "PAYOUT_ACCOUNT_DEFAULT_FAILED", err.Error())
+	}
+	return response.OK(c, map[string]bool{"updated": true})
+}
@@ -3,6 +3,8 @@ package handlers
 import (
 	"errors"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/labstack/echo/v4"
 
@@ -26,15 +28,31 @@ func (h *ReferralHandler) Bind(c echo.Context) error {
 	if err := c.Bind(&body); err != nil || body.Code == "" {
 		return response.Fail(c, http.StatusBadRequest, "BAD_REQUEST", "code is required")
 	}
-	err := h.svc.Bind(userID(c), body.Code)
+	err := h.svc.Bind(userID(c), body.Code, requestMeta(c))
 	if err != nil {
 		switch {
 		case errors.Is(err, service.ErrAlreadyBound):
 			return response.Fail(c, http.StatusConflict, "REFERRAL_ALREADY_BOUND", err.Error())
 		case errors.Is(err, service.ErrBindSelf):
 			return response.Fail(c, http.StatusBadRequest, "REFERRAL_BIND_SELF", err.Error())
+		case errors.Is(err, service.ErrBindCycle):
+			return response.Fail(c, http.StatusBadRequest, "REFERRAL_BIND_CYCLE", err.Error())
+		case errors.Is(err, service.ErrBindWindowClosed):
+			return response.Fail(c, http.StatusBadRequest, "REFERRAL_BIND_WINDOW_CLOSED", err.Error())
+		case errors.Is(err, service.ErrBindAfterTask):
+			return response.Fail(c, http.StatusBadRequest, "REFERRAL_BIND_AFTER_TASK", err.Error())
+		case errors.Is(err, service.ErrBindInviterNewer):
+			return response.Fail(c, http.StatusBadRequest, "REFERRAL_INVITER_NEWER", err.Error())
+		case errors.Is(err, service.ErrBindSharedDevice):
+			return response.Fail(c, http.StatusForbidden, "REFERRAL_SHARED_DEVICE", err.Error())
+		case errors.Is(err, service.ErrBindSharedIP):
+			return response.Fail(c, http.StatusForbidden, "REFERRAL_SHARED_IP", err.Error())
+		case errors.Is(err, service.ErrReferralCodeChecksum):
+			return response.Fail(c, http.StatusBadRequest, "REFERRAL_CODE_TYPO", err.Error())
 		case errors.Is(err, service.ErrReferralCode):
 			return response.Fail(c, http.StatusBadRequest, "REFERRAL_INVALID_CODE", err.Error())
+		case errors.Is(err, service.ErrRiskCheckFailed):
+			return response.Fail(c, http.StatusBadRequest, "RISK_CHECK_FAILED", err.Error())
 		default:
 			return response.Fail(c, http.StatusInternalServerError, "REFERRAL_BIND_FAILED", err.Error())
 		}
@@ -43,9 +61,71 @@ func (h *ReferralHandler) Bind(c echo.Context) error {
 }
 
 func (h *ReferralHandler) Status(c echo.Context) error {
-	data, err := h.svc.Status(userID(c))
+	level, _ := strconv.Atoi(c.QueryParam("level"))
+	page, _ := strconv.Atoi(c.QueryParam("page"))
+	size, _ := strconv.Atoi(c.QueryParam("size"))
+	data, err := h.svc.Status(userID(c), level, page, size)
 	if err != nil {
 		return response.Fail(c, http.StatusInternalServerError, "REFERRAL_STATUS_FAILED", err.Error())
 	}
 	return response.OK(c, data)
 }
+
+func (h *ReferralHandler) RotateCode(c echo.Context) error {
+	item, err := h.svc.RotateCode(userID(c))
+	if err != nil {
+		if errors.Is(err, service.ErrReferralCodeCooldown) {
+			return response.Fail(c, http.StatusTooManyRequests, "REFERRAL_CODE_COOLDOWN", err.Error())
+		}
+		return response.Fail(c, http.StatusInternalServerError, "REFERRAL_CODE_ROTATE_FAILED", err.Error())
+	}
+	return response.OK(c, map[string]string{"code": item.Code})
+}
+
+const attributionCookie = "rp_attr"
+
+func (h *ReferralHandler) Landing(c echo.Context) error {
+	landing, err := h.svc.RecordClick(c.Param("code"), requestMeta(c))
+	wantsJSON := strings.Contains(c.Request().Header.Get(echo.HeaderAccept), echo.MIMEApplicationJSON)
+	if err != nil {
+		invalid := errors.Is(err, service.ErrReferralCode) || errors.Is(err, service.ErrReferralCodeChecksum)
+		switch {
+		case invalid && wantsJSON:
+			return response.Fail(c, http.StatusNotFound, "REFERRAL_INVALID_CODE", err.Error())
+		case invalid:
+			return c.Redirect(http.StatusFound, landing.RedirectURL)
+		default:
+			return response.Fail(c, http.StatusInternalServerError, "REFERRAL_CLICK_FAILED", err.Error())
+		}
+	}
+	c.SetCookie(&http.Cookie{
+		Name:     attributionCookie,
+		Value:    landing.Token,
+		Path:     "/",
+		MaxAge:   landing.TTLSeconds,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	if wantsJSON {
+		return response.OK(c, landing)
+	}
+	return c.Redirect(http.StatusFound, landing.RedirectURL)
+}
+
+func (h *ReferralHandler) ClickStats(c echo.Context) error {
+	days, _ := strconv.Atoi(c.QueryParam("days"))
+	data, err := h.svc.ClickStats(userID(c), days)
+	if err != nil {
+		return response.Fail(c, http.StatusInternalServerError, "REFERRAL_CLICK_STATS_FAILED", err.Error())
+	}
+	return response.OK(c, data)
+}
+
+func (h *ReferralHandler) Leaderboard(c echo.Context) error {
+	previous := c.QueryParam("previous") == "1" || c.QueryParam("previous") == "true"
+	data, err := h.svc.Leaderboard(c.QueryParam("period"), previous)
+	if err != nil {
+		return response.Fail(c, http.StatusInternalServerError, "REFERRAL_LEADERBOARD_FAILED", err.Error())
+	}
+	return response.OK(c, data)
+}
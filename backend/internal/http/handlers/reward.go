@@ -29,7 +29,7 @@ func (h *RewardHandler) Summary(c echo.Context) error {
 }
 
 func (h *RewardHandler) Unlock(c echo.Context) error {
-	count, summary, err := h.svc.UnlockPendingRewards(userID(c))
+	count, summary, err := h.svc.UnlockPendingRewards(userID(c), requestMeta(c))
 	if err != nil {
 		if errors.Is(err, service.ErrRiskCheckFailed) {
 			return response.Fail(c, http.StatusBadRequest, "RISK_CHECK_FAILED", err.Error())
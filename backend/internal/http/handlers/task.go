@@ -3,7 +3,7 @@ package handlers
 import (
 	"errors"
 	"net/http"
-	"strings"
+	"strconv"
 
 	"github.com/labstack/echo/v4"
 
@@ -24,13 +24,25 @@ func (h *TaskHandler) Claim(c echo.Context) error {
 	if err := c.Bind(&req); err != nil {
 		return response.Fail(c, http.StatusBadRequest, "BAD_REQUEST", "invalid request")
 	}
-	spinCount, err := h.svc.Claim(userID(c), req)
+	spinCount, err := h.svc.Claim(userID(c), req, requestMeta(c))
 	if err != nil {
 		switch {
 		case errors.Is(err, service.ErrAlreadyClaimed):
 			return response.Fail(c, http.StatusConflict, "TASK_ALREADY_CLAIMED", err.Error())
 		case errors.Is(err, service.ErrTaskNotFound):
 			return response.Fail(c, http.StatusNotFound, "TASK_NOT_FOUND", err.Error())
+		case errors.Is(err, service.ErrTaskLocked):
+			return response.Fail(c, http.StatusConflict, "TASK_LOCKED", err.Error())
+		case errors.Is(err, service.ErrTaskUnverified):
+			return response.Fail(c, http.StatusConflict, "TASK_NOT_VERIFIED", err.Error())
+		case errors.Is(err, service.ErrTaskOutOfScope):
+			return response.Fail(c, http.StatusForbidden, "TASK_OUT_OF_SCOPE", err.Error())
+		case errors.Is(err, service.ErrCampaignInactive):
+			return response.Fail(c, http.StatusBadRequest, "CAMPAIGN_INACTIVE", err.Error())
+		case errors.Is(err, service.ErrCampaignOutOfScope):
+			return response.Fail(c, http.StatusForbidden, "CAMPAIGN_OUT_OF_SCOPE", err.Error())
+		case errors.Is(err, service.ErrRiskCheckFailed):
+			return response.Fail(c, http.StatusBadRequest, "RISK_CHECK_FAILED", err.Error())
 		default:
 			return response.Fail(c, http.StatusInternalServerError, "TASK_CLAIM_FAILED", err.Error())
 		}
@@ -42,10 +54,38 @@ func (h *TaskHandler) Claim(c echo.Context) error {
 }
 
 func (h *TaskHandler) List(c echo.Context) error {
-	country := strings.TrimSpace(c.QueryParam("country"))
-	items, err := h.svc.ListForUser(userID(c), country)
+	campaignID, _ := strconv.ParseUint(c.QueryParam("campaign_id"), 10, 64)
+	items, err := h.svc.ListForUser(userID(c), uint(campaignID), requestMeta(c))
 	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrCampaignNotFound):
+			return response.Fail(c, http.StatusNotFound, "CAMPAIGN_NOT_FOUND", err.Error())
+		case errors.Is(err, service.ErrCampaignInactive):
+			return response.Fail(c, http.StatusBadRequest, "CAMPAIGN_INACTIVE", err.Error())
+		case errors.Is(err, service.ErrCampaignOutOfScope):
+			return response.Fail(c, http.StatusForbidden, "CAMPAIGN_OUT_OF_SCOPE", err.Error())
+		}
 		return response.Fail(c, http.StatusInternalServerError, "TASK_LIST_FAILED", err.Error())
 	}
 	return response.OK(c, map[string]interface{}{"items": items})
 }
+
+func (h *TaskHandler) Callback(c echo.Context) error {
+	var req service.TaskCallbackInput
+	if err := c.Bind(&req); err != nil {
+		return response.Fail(c, http.StatusBadRequest, "BAD_REQUEST", "invalid request")
+	}
+	completion, err := h.svc.RecordCallback(c.Param("type"), req)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrTaskCallbackSignature):
+			return response.Fail(c, http.StatusUnauthorized, "TASK_CALLBACK_SIGNATURE", err.Error())
+		case errors.Is(err, service.ErrTaskCallbackInvalid):
+			return response.Fail(c, http.StatusBadRequest, "TASK_CALLBACK_INVALID", err.Error())
+		case errors.Is(err, service.ErrTaskNotFound):
+			return response.Fail(c, http.StatusNotFound, "TASK_NOT_FOUND", err.Error())
+		}
+		return response.Fail(c, http.StatusInternalServerError, "TASK_CALLBACK_FAILED", err.Error())
+	}
+	return response.OK(c, map[string]interface{}{"id": completion.ID, "task_id": completion.TaskID})
+}
@@ -1,9 +1,12 @@
 package handlers
 
 import (
+	"strings"
+
 	"github.com/labstack/echo/v4"
 
 	"red_packet/backend/internal/http/middleware"
+	"red_packet/backend/internal/service"
 )
 
 func userID(c echo.Context) uint {
@@ -13,3 +16,11 @@ func userID(c echo.Context) uint {
 	}
 	return v.(uint)
 }
+
+func requestMeta(c echo.Context) service.RequestMeta {
+	return service.RequestMeta{
+		IP:         c.RealIP(),
+		DeviceHash: strings.TrimSpace(c.Request().Header.Get("X-Device-Hash")),
+		UserAgent:  c.Request().UserAgent(),
+	}
+}
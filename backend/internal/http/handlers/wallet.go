@@ -21,7 +21,7 @@ func NewWalletHandler(svc *service.WalletService) *WalletHandler {
 func (h *WalletHandler) Get(c echo.Context) error {
 	page, _ := strconv.Atoi(c.QueryParam("page"))
 	size, _ := strconv.Atoi(c.QueryParam("size"))
-	data, err := h.svc.Get(userID(c), page, size)
+	data, err := h.svc.Get(userID(c), c.QueryParam("currency"), page, size)
 	if err != nil {
 		return response.Fail(c, http.StatusInternalServerError, "WALLET_FETCH_FAILED", err.Error())
 	}
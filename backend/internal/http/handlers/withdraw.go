@@ -22,13 +22,15 @@ func NewWithdrawHandler(svc *service.WithdrawService) *WithdrawHandler {
 
 func (h *WithdrawHandler) Apply(c echo.Context) error {
 	type req struct {
-		Amount float64 `json:"amount"`
+		Amount    float64 `json:"amount"`
+		AccountID uint    `json:"account_id"`
+		Currency  string  `json:"currency"`
 	}
 	var body req
 	if err := c.Bind(&body); err != nil {
 		return response.Fail(c, http.StatusBadRequest, "BAD_REQUEST", "invalid request")
 	}
-	data, err := h.svc.Apply(userID(c), body.Amount)
+	data, err := h.svc.Apply(userID(c), body.Amount, body.AccountID, body.Currency, requestMeta(c))
 	if err != nil {
 		if errors.Is(err, service.ErrInsufficientFunds) {
 			return response.Fail(c, http.StatusBadRequest, "INSUFFICIENT_FUNDS", err.Error())
@@ -39,14 +41,103 @@ func (h *WithdrawHandler) Apply(c echo.Context) error {
 		if errors.Is(err, service.ErrWithdrawBelowMin) {
 			return response.Fail(c, http.StatusBadRequest, "WITHDRAW_BELOW_MIN", err.Error())
 		}
+		if errors.Is(err, service.ErrWithdrawAboveMax) {
+			return response.Fail(c, http.StatusBadRequest, "WITHDRAW_ABOVE_MAX", err.Error())
+		}
+		if errors.Is(err, service.ErrWithdrawAmountNotAllowed) {
+			return response.Fail(c, http.StatusBadRequest, "WITHDRAW_AMOUNT_NOT_ALLOWED", err.Error())
+		}
+		if errors.Is(err, service.ErrWithdrawFirstAmount) {
+			return response.Fail(c, http.StatusBadRequest, "WITHDRAW_FIRST_AMOUNT_REQUIRED", err.Error())
+		}
+		if errors.Is(err, service.ErrWithdrawCooldown) {
+			return response.Fail(c, http.StatusTooManyRequests, "WITHDRAW_COOLDOWN", err.Error())
+		}
+		if errors.Is(err, service.ErrWithdrawDailyCount) {
+			return response.Fail(c, http.StatusTooManyRequests, "WITHDRAW_DAILY_COUNT_LIMIT", err.Error())
+		}
+		if errors.Is(err, service.ErrWithdrawWeeklyCount) {
+			return response.Fail(c, http.StatusTooManyRequests, "WITHDRAW_WEEKLY_COUNT_LIMIT", err.Error())
+		}
+		if errors.Is(err, service.ErrWithdrawDailyAmount) {
+			return response.Fail(c, http.StatusBadRequest, "WITHDRAW_DAILY_AMOUNT_LIMIT", err.Error())
+		}
+		if errors.Is(err, service.ErrWithdrawFeeExceeds) {
+			return response.Fail(c, http.StatusBadRequest, "WITHDRAW_FEE_EXCEEDS_AMOUNT", err.Error())
+		}
 		if errors.Is(err, service.ErrRiskCheckFailed) {
 			return response.Fail(c, http.StatusBadRequest, "RISK_CHECK_FAILED", err.Error())
 		}
+		if errors.Is(err, service.ErrKYCRequired) {
+			return response.Fail(c, http.StatusForbidden, "KYC_REQUIRED", err.Error())
+		}
+		if errors.Is(err, service.ErrPayoutAccountRequired) {
+			return response.Fail(c, http.StatusBadRequest, "PAYOUT_ACCOUNT_REQUIRED", err.Error())
+		}
+		if errors.Is(err, service.ErrPayoutAccountNotFound) {
+			return response.Fail(c, http.StatusNotFound, "PAYOUT_ACCOUNT_NOT_FOUND", err.Error())
+		}
+		if errors.Is(err, service.ErrPayoutAccountCooldown) {
+			return response.Fail(c, http.StatusBadRequest, "PAYOUT_ACCOUNT_COOLDOWN", err.Error())
+		}
+		if errors.Is(err, service.ErrPayoutAccountUnverified) {
+			return response.Fail(c, http.StatusBadRequest, "PAYOUT_ACCOUNT_UNVERIFIED", err.Error())
+		}
 		return response.Fail(c, http.StatusInternalServerError, "WITHDRAW_APPLY_FAILED", err.Error())
 	}
 	return response.OK(c, data)
 }
 
+func (h *WithdrawHandler) Quote(c echo.Context) error {
+	type req struct {
+		Amount    float64 `json:"amount"`
+		AccountID uint    `json:"account_id"`
+		Currency  string  `json:"currency"`
+	}
+	var body req
+	if err := c.Bind(&body); err != nil {
+		return response.Fail(c, http.StatusBadRequest, "BAD_REQUEST", "invalid request")
+	}
+	data, err := h.svc.Quote(userID(c), body.Amount, body.AccountID, body.Currency)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidAmount):
+			return response.Fail(c, http.StatusBadRequest, "INVALID_AMOUNT", err.Error())
+		case errors.Is(err, service.ErrWithdrawFeeExceeds):
+			return response.Fail(c, http.StatusBadRequest, "WITHDRAW_FEE_EXCEEDS_AMOUNT", err.Error())
+		case errors.Is(err, service.ErrPayoutAccountRequired):
+			return response.Fail(c, http.StatusBadRequest, "PAYOUT_ACCOUNT_REQUIRED", err.Error())
+		case errors.Is(err, service.ErrPayoutAccountNotFound):
+			return response.Fail(c, http.StatusNotFound, "PAYOUT_ACCOUNT_NOT_FOUND", err.Error())
+		default:
+			return response.Fail(c, http.StatusInternalServerError, "WITHDRAW_QUOTE_FAILED", err.Error())
+		}
+	}
+	return response.OK(c, data)
+}
+
+func (h *WithdrawHandler) Cancel(c echo.Context) error {
+	type req struct {
+		RequestID uint `json:"request_id"`
+	}
+	var body req
+	if err := c.Bind(&body); err != nil || body.RequestID == 0 {
+		return response.Fail(c, http.StatusBadRequest, "BAD_REQUEST", "request_id is required")
+	}
+	data, err := h.svc.Cancel(userID(c), body.RequestID)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrWithdrawNotFound):
+			return response.Fail(c, http.StatusNotFound, "WITHDRAW_NOT_FOUND", err.Error())
+		case errors.Is(err, service.ErrWithdrawState):
+			return response.Fail(c, http.StatusBadRequest, "WITHDRAW_STATE_INVALID", err.Error())
+		default:
+			return response.Fail(c, http.StatusInternalServerError, "WITHDRAW_CANCEL_FAILED", err.Error())
+		}
+	}
+	return response.OK(c, data)
+}
+
 func (h *WithdrawHandler) Records(c echo.Context) error {
 	page, _ := strconv.Atoi(c.QueryParam("page"))
 	size, _ := strconv.Atoi(c.QueryParam("size"))
@@ -0,0 +1,92 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"red_packet/backend/internal/http/response"
+	"red_packet/backend/internal/service"
+)
+
+const HeaderIdempotencyKey = "Idempotency-Key"
+
+type bodyRecorder struct {
+	http.ResponseWriter
+	buf *bytes.Buffer
+}
+
+func (w *bodyRecorder) Write(b []byte) (int, error) {
+	w.buf.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+// Idempotency replays the stored response when a JWT-authenticated request is
+// retried with the same Idempotency-Key. Requests without the header pass through.
+func Idempotency(svc *service.IdempotencyService) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			key := strings.TrimSpace(c.Request().Header.Get(HeaderIdempotencyKey))
+			if key == "" {
+				return next(c)
+			}
+			if len(key) > 128 {
+				return response.Fail(c, http.StatusBadRequest, "IDEMPOTENCY_KEY_INVALID", "idempotency key too long")
+			}
+			uid, _ := c.Get(CtxUserID).(uint)
+
+			body, err := io.ReadAll(c.Request().Body)
+			if err != nil {
+				return response.Fail(c, http.StatusBadRequest, "BAD_REQUEST", "invalid request")
+			}
+			c.Request().Body = io.NopCloser(bytes.NewReader(body))
+			// The concrete path, not the route template, so one key cannot
+			// replay a response across different :token/:id values.
+			route := c.Request().Method + " " + c.Request().URL.Path
+			sum := sha256.Sum256(append([]byte(route+"\n"), body...))
+
+			rec, replay, err := svc.Begin(uid, key, route, hex.EncodeToString(sum[:]))
+			if err != nil {
+				switch {
+				case errors.Is(err, service.ErrIdempotencyInProgress):
+					return response.Fail(c, http.StatusConflict, "IDEMPOTENCY_IN_PROGRESS", err.Error())
+				case errors.Is(err, service.ErrIdempotencyMismatch):
+					return response.Fail(c, http.StatusUnprocessableEntity, "IDEMPOTENCY_KEY_REUSED", err.Error())
+				default:
+					return response.Fail(c, http.StatusInternalServerError, "IDEMPOTENCY_FAILED", err.Error())
+				}
+			}
+			if replay {
+				c.Response().Header().Set("Idempotent-Replayed", "true")
+				return c.Blob(rec.ResponseStatus, echo.MIMEApplicationJSONCharsetUTF8, []byte(rec.ResponseBody))
+			}
+
+			recorder := &bodyRecorder{ResponseWriter: c.Response().Writer, buf: &bytes.Buffer{}}
+			c.Response().Writer = recorder
+			completed := false
+			defer func() {
+				// A panic is handled by Recover further out; free the key so
+				// the client can retry instead of getting 409 until expiry.
+				if !completed {
+					_ = svc.Release(rec.ID)
+				}
+			}()
+			if err := next(c); err != nil {
+				c.Error(err)
+			}
+			status := c.Response().Status
+			if status >= http.StatusInternalServerError {
+				return nil
+			}
+			completed = true
+			_ = svc.Complete(rec.ID, status, recorder.buf.String())
+			return nil
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package middleware
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"red_packet/backend/internal/config"
+	"red_packet/backend/internal/http/response"
+)
+
+// RateLimitStore counts hits per key in fixed windows. Implementations backed by
+// a shared store (e.g. Redis) let several server instances enforce one limit.
+type RateLimitStore interface {
+	Hit(key string, limit int, window time.Duration) (allowed bool, retryAfter time.Duration, err error)
+}
+
+type memoryBucket struct {
+	count   int
+	resetAt time.Time
+}
+
+type MemoryRateLimitStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*memoryBucket
+	lastSweep time.Time
+}
+
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{buckets: map[string]*memoryBucket{}, lastSweep: time.Now()}
+}
+
+func (s *MemoryRateLimitStore) Hit(key string, limit int, window time.Duration) (bool, time.Duration, error) {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.Sub(s.lastSweep) > time.Minute {
+		for k, b := range s.buckets {
+			if now.After(b.resetAt) {
+				delete(s.buckets, k)
+			}
+		}
+		s.lastSweep = now
+	}
+
+	b, ok := s.buckets[key]
+	if !ok || now.After(b.resetAt) {
+		b = &memoryBucket{resetAt: now.Add(window)}
+		s.buckets[key] = b
+	}
+	if b.count >= limit {
+		return false, b.resetAt.Sub(now), nil
+	}
+	b.count++
+	return true, 0, nil
+}
+
+func RateLimit(cfg config.Config, store RateLimitStore) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if !cfg.RateLimit.Enabled {
+				return next(c)
+			}
+			route := c.Request().Method + " " + c.Path()
+			for _, p := range cfg.RateLimit.Policies {
+				if p.Limit <= 0 || (p.Route != "*" && p.Route != route) {
+					continue
+				}
+				doneKey := "ratelimit:" + p.Name
+				if c.Get(doneKey) != nil {
+					continue
+				}
+				subject := rateLimitSubject(c, p.Key)
+				if subject == "" {
+					continue
+				}
+				c.Set(doneKey, true)
+
+				window := time.Duration(p.WindowSeconds) * time.Second
+				if window <= 0 {
+					window = time.Minute
+				}
+				allowed, retryAfter, err := store.Hit(fmt.Sprintf("%s:%s:%s", p.Name, p.Key, subject), p.Limit, window)
+				if err != nil || allowed {
+					continue
+				}
+				seconds := int(math.Ceil(retryAfter.Seconds()))
+				if seconds < 1 {
+					seconds = 1
+				}
+				c.Response().Header().Set("Retry-After", fmt.Sprintf("%d", seconds))
+				return c.JSON(http.StatusTooManyRequests, response.Envelope{
+					Code:    "RATE_LIMITED",
+					Message: "too many requests",
+					Data:    map[string]interface{}{"retry_after": seconds, "policy": p.Name},
+				})
+			}
+			return next(c)
+		}
+	}
+}
+
+func rateLimitSubject(c echo.Context, key string) string {
+	switch key {
+	case "ip":
+		return c.RealIP()
+	case "user":
+		if uid, ok := c.Get(CtxUserID).(uint); ok && uid > 0 {
+			return fmt.Sprintf("%d", uid)
+		}
+	case "device":
+		return strings.TrimSpace(c.Request().Header.Get("X-Device-Hash"))
+	}
+	return ""
+}
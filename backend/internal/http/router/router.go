@@ -2,7 +2,9 @@ package router
 
 import (
 	"log"
+	"net"
 	"net/http"
+	"strings"
 
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
@@ -15,6 +17,7 @@ import (
 
 func New(svcs *service.Container, cfg config.Config) *echo.Echo {
 	e := echo.New()
+	e.IPExtractor = ipExtractor(cfg.Server.TrustedProxies)
 	e.Use(middleware.Recover())
 	e.Use(middleware.RequestLoggerWithConfig(middleware.RequestLoggerConfig{
 		LogURI:    true,
@@ -34,7 +37,12 @@ func New(svcs *service.Container, cfg config.Config) *echo.Echo {
 		return c.JSON(http.StatusOK, map[string]string{"status": "ok"})
 	})
 
+	rateLimiter := appMiddleware.RateLimit(cfg, appMiddleware.NewMemoryRateLimitStore())
+	referralHandler := handlers.NewReferralHandler(svcs.Referral)
+	e.GET("/r/:code", referralHandler.Landing, rateLimiter)
+
 	api := e.Group("/api")
+	api.Use(rateLimiter)
 	authHandler := handlers.NewAuthHandler(svcs.Auth)
 	configHandler := handlers.NewConfigHandler(svcs.Config)
 
@@ -42,45 +50,121 @@ func New(svcs *service.Container, cfg config.Config) *echo.Echo {
 	api.POST("/auth/otp", authHandler.OTP)
 	api.GET("/config/bootstrap", configHandler.Bootstrap)
 
+	assistHandler := handlers.NewAssistHandler(svcs.Assist)
+	api.GET("/assist/session/:token", assistHandler.Get)
+	api.GET("/referral/leaderboard", referralHandler.Leaderboard)
+
+	taskHandler := handlers.NewTaskHandler(svcs.Task)
+	api.POST("/task/callback/:type", taskHandler.Callback)
+
 	authGroup := api.Group("")
-	authGroup.Use(appMiddleware.JWT(cfg))
+	authGroup.Use(appMiddleware.JWT(cfg), rateLimiter)
 
-	referralHandler := handlers.NewReferralHandler(svcs.Referral)
 	rewardHandler := handlers.NewRewardHandler(svcs.Reward)
-	taskHandler := handlers.NewTaskHandler(svcs.Task)
 	lotteryHandler := handlers.NewLotteryHandler(svcs.Lottery)
 	walletHandler := handlers.NewWalletHandler(svcs.Wallet)
 	withdrawHandler := handlers.NewWithdrawHandler(svcs.Withdraw)
-	adminHandler := handlers.NewAdminHandler(svcs.Withdraw, svcs.Task, svcs.Config, svcs.Risk, svcs.AdminOps)
+	payoutHandler := handlers.NewPayoutHandler(svcs.Payout)
+	kycHandler := handlers.NewKYCHandler(svcs.KYC)
+	adminHandler := handlers.NewAdminHandler(svcs.Withdraw, svcs.Task, svcs.Config, svcs.Risk, svcs.AdminOps, svcs.Payout, svcs.KYC, svcs.Referral, svcs.Assist, svcs.Campaign, svcs.Currency)
+
+	idempotent := appMiddleware.Idempotency(svcs.Idempotency)
 
-	authGroup.POST("/referral/bind", referralHandler.Bind)
+	authGroup.POST("/referral/bind", referralHandler.Bind, idempotent)
 	authGroup.GET("/referral/status", referralHandler.Status)
+	authGroup.POST("/referral/code/rotate", referralHandler.RotateCode)
+	authGroup.GET("/referral/clicks/stats", referralHandler.ClickStats)
+	authGroup.POST("/assist/open", assistHandler.Open)
+	authGroup.GET("/assist/current", assistHandler.Current)
+	authGroup.POST("/assist/session/:token/help", assistHandler.Help, idempotent)
 	authGroup.GET("/reward/summary", rewardHandler.Summary)
 	authGroup.GET("/reward/records", rewardHandler.Records)
-	authGroup.POST("/reward/unlock", rewardHandler.Unlock)
+	authGroup.POST("/reward/unlock", rewardHandler.Unlock, idempotent)
 	authGroup.GET("/task/list", taskHandler.List)
 	authGroup.POST("/task/claim", taskHandler.Claim)
 	authGroup.GET("/lottery/status", lotteryHandler.Status)
-	authGroup.POST("/lottery/spin", lotteryHandler.Spin)
+	authGroup.POST("/lottery/spin", lotteryHandler.Spin, idempotent)
 	authGroup.GET("/lottery/records", lotteryHandler.Records)
 	authGroup.GET("/wallet", walletHandler.Get)
-	authGroup.POST("/withdraw/apply", withdrawHandler.Apply)
+	authGroup.POST("/withdraw/quote", withdrawHandler.Quote)
+	authGroup.POST("/withdraw/apply", withdrawHandler.Apply, idempotent)
+	authGroup.POST("/withdraw/cancel", withdrawHandler.Cancel, idempotent)
 	authGroup.GET("/withdraw/records", withdrawHandler.Records)
+	authGroup.GET("/payout/accounts", payoutHandler.List)
+	authGroup.POST("/payout/account/bind", payoutHandler.Bind)
+	authGroup.POST("/payout/account/remove", payoutHandler.Remove)
+	authGroup.POST("/payout/account/default", payoutHandler.SetDefault)
+	authGroup.GET("/kyc/status", kycHandler.Status)
+	authGroup.POST("/kyc/submit", kycHandler.Submit)
 
 	adminGroup := api.Group("/admin")
 	adminGroup.Use(appMiddleware.AdminKey(cfg))
 	adminGroup.GET("/dashboard", adminHandler.Dashboard)
 	adminGroup.GET("/task/list", adminHandler.ListTasks)
+	adminGroup.GET("/task/types", adminHandler.TaskTypes)
 	adminGroup.POST("/task/save", adminHandler.SaveTask)
 	adminGroup.DELETE("/task/:id", adminHandler.DeleteTask)
+	adminGroup.GET("/campaign/list", adminHandler.ListCampaigns)
+	adminGroup.POST("/campaign/save", adminHandler.SaveCampaign)
+	adminGroup.GET("/fx/rates", adminHandler.ListFXRates)
+	adminGroup.POST("/fx/rate", adminHandler.UpsertFXRate)
+	adminGroup.GET("/report/spend", adminHandler.SpendReport)
 	adminGroup.GET("/config/list", adminHandler.ListConfigs)
 	adminGroup.POST("/config/upsert", adminHandler.UpsertConfig)
 	adminGroup.GET("/risk/flags", adminHandler.ListRiskFlags)
 	adminGroup.POST("/risk/flag/add", adminHandler.AddRiskFlag)
+	adminGroup.POST("/risk/flag/update", adminHandler.UpdateRiskFlag)
+	adminGroup.GET("/risk/score", adminHandler.RiskScore)
+	adminGroup.GET("/risk/rules", adminHandler.ListRiskRules)
+	adminGroup.GET("/risk/decisions", adminHandler.ListRiskDecisions)
+	adminGroup.GET("/risk/detectors", adminHandler.ListRiskDetectors)
+	adminGroup.POST("/risk/detect/run", adminHandler.RunRiskDetectors)
 	adminGroup.GET("/blacklist/list", adminHandler.ListBlacklists)
 	adminGroup.POST("/blacklist/add", adminHandler.AddBlacklist)
 	adminGroup.GET("/withdraw/list", adminHandler.ListWithdraw)
 	adminGroup.POST("/withdraw/review", adminHandler.ReviewWithdraw)
+	adminGroup.POST("/withdraw/expire", adminHandler.ExpireWithdraws)
+	adminGroup.GET("/payout/accounts", adminHandler.ListPayoutAccounts)
+	adminGroup.POST("/payout/verify", adminHandler.VerifyPayoutAccount)
+	adminGroup.GET("/referral/clicks", adminHandler.ReferralClickStats)
+	adminGroup.POST("/referral/code/rotate", adminHandler.RotateReferralCode)
+	adminGroup.POST("/referral/code/vanity", adminHandler.SetVanityReferralCode)
+	adminGroup.POST("/referral/code/rotate-legacy", adminHandler.RotateLegacyReferralCodes)
+	adminGroup.POST("/referral/leaderboard/settle", adminHandler.SettleLeaderboards)
+	adminGroup.GET("/assist/sessions", adminHandler.ListAssistSessions)
+	adminGroup.GET("/kyc/list", adminHandler.ListKYC)
+	adminGroup.POST("/kyc/review", adminHandler.ReviewKYC)
 
 	return e
 }
+
+// ipExtractor decides what c.RealIP() returns. Without trusted proxies the
+// socket peer is used and forwarding headers are ignored; otherwise
+// X-Forwarded-For is honoured only for hops inside the configured ranges.
+func ipExtractor(proxies []string) echo.IPExtractor {
+	var opts []echo.TrustOption
+	for _, p := range proxies {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		if !strings.Contains(p, "/") {
+			if ip := net.ParseIP(p); ip != nil && ip.To4() != nil {
+				p += "/32"
+			} else {
+				p += "/128"
+			}
+		}
+		_, ipNet, err := net.ParseCIDR(p)
+		if err != nil {
+			log.Printf("ignore invalid trusted proxy %q: %v", p, err)
+			continue
+		}
+		opts = append(opts, echo.TrustIPRange(ipNet))
+	}
+	if len(opts) == 0 {
+		return echo.ExtractIPDirect()
+	}
+	opts = append(opts, echo.TrustLoopback(false), echo.TrustLinkLocal(false), echo.TrustPrivateNet(false))
+	return echo.ExtractIPFromXFFHeader(opts...)
+}
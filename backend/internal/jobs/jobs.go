@@ -0,0 +1,51 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"red_packet/backend/internal/service"
+)
+
+type job struct {
+	name     string
+	interval time.Duration
+	run      func(now time.Time) (int, error)
+}
+
+func Start(ctx context.Context, svcs *service.Container) {
+	list := []job{
+		{name: "withdraw_expire", interval: 10 * time.Minute, run: svcs.Withdraw.ExpireStale},
+		{name: "withdraw_sla_flag", interval: 10 * time.Minute, run: svcs.Withdraw.FlagStaleApproved},
+		{name: "risk_detect", interval: 5 * time.Minute, run: svcs.Risk.RunDetectors},
+		{name: "risk_flag_expire", interval: 10 * time.Minute, run: svcs.Risk.ExpireFlags},
+		{name: "assist_expire", interval: 10 * time.Minute, run: svcs.Assist.ExpireSessions},
+		{name: "invite_validate", interval: 10 * time.Minute, run: svcs.Referral.ValidatePendingInvites},
+		{name: "leaderboard_settle", interval: time.Hour, run: svcs.Referral.SettleLeaderboards},
+		{name: "idempotency_purge", interval: time.Hour, run: svcs.Idempotency.PurgeExpired},
+	}
+	for _, j := range list {
+		go loop(ctx, j)
+	}
+}
+
+func loop(ctx context.Context, j job) {
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			n, err := j.run(now)
+			if err != nil {
+				log.Printf("job %s failed: %v", j.name, err)
+				continue
+			}
+			if n > 0 {
+				log.Printf("job %s processed %d", j.name, n)
+			}
+		}
+	}
+}
@@ -4,19 +4,40 @@ import "time"
 
 type User struct {
 	ID         uint      `gorm:"primaryKey" json:"id"`
-	Phone      string    `gorm:"size:32;index" json:"phone"`
-	Email      string    `gorm:"size:128;index" json:"email"`
+	Phone      *string   `gorm:"size:32;uniqueIndex:uk_users_phone" json:"phone"`
+	Email      *string   `gorm:"size:128;uniqueIndex:uk_users_email" json:"email"`
 	Country    string    `gorm:"size:16" json:"country"`
 	Language   string    `gorm:"size:16" json:"language"`
+	Currency   string    `gorm:"size:8" json:"currency"` // wallet currency, fixed at registration
 	DeviceHash string    `gorm:"size:128;index" json:"device_hash"`
+	KYCStatus  string    `gorm:"size:16;index;default:none" json:"kyc_status"` // none/submitted/verified/rejected
+	Restricted bool      `gorm:"index;default:false" json:"restricted"`
+	RegisterIP string    `gorm:"size:64;index" json:"-"`
 	CreatedAt  time.Time `json:"created_at"`
 }
 
 type ReferralCode struct {
-	ID        uint   `gorm:"primaryKey"`
-	UserID    uint   `gorm:"uniqueIndex"`
-	Code      string `gorm:"size:32;uniqueIndex"`
-	CreatedAt time.Time
+	ID           uint       `gorm:"primaryKey" json:"id"`
+	UserID       uint       `gorm:"index:idx_referral_codes_owner" json:"user_id"`
+	Code         string     `gorm:"size:32;uniqueIndex" json:"code"`
+	Kind         string     `gorm:"size:16;default:generated" json:"kind"` // generated/vanity/legacy
+	ActiveUserID *uint      `gorm:"uniqueIndex" json:"-"`
+	RetiredAt    *time.Time `json:"retired_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+type ReferralClick struct {
+	ID               uint       `gorm:"primaryKey" json:"id"`
+	Code             string     `gorm:"size:32;index" json:"code"`
+	InviterUserID    uint       `gorm:"index:idx_referral_clicks_inviter_created" json:"inviter_user_id"`
+	Token            string     `gorm:"size:64;uniqueIndex" json:"-"`
+	IP               string     `gorm:"size:64;index" json:"ip"`
+	UserAgent        string     `gorm:"size:255" json:"user_agent"`
+	DeviceHash       string     `gorm:"size:128;index" json:"device_hash"`
+	RegisteredUserID *uint      `gorm:"uniqueIndex" json:"registered_user_id"`
+	Bound            bool       `gorm:"default:false" json:"bound"`
+	ConvertedAt      *time.Time `json:"converted_at"`
+	CreatedAt        time.Time  `gorm:"index:idx_referral_clicks_inviter_created" json:"created_at"`
 }
 
 type ReferralEdge struct {
@@ -26,12 +47,14 @@ type ReferralEdge struct {
 	Level        int  `gorm:"uniqueIndex:uniq_child_level"`
 	IsValid      bool `gorm:"index;default:false"`
 	ValidatedAt  *time.Time
+	CheckedAt    *time.Time `gorm:"index"` // last re-check by the validity job
 	CreatedAt    time.Time
 }
 
 type Wallet struct {
 	ID        uint    `gorm:"primaryKey"`
-	UserID    uint    `gorm:"uniqueIndex"`
+	UserID    uint    `gorm:"uniqueIndex:uniq_wallet_currency"`
+	Currency  string  `gorm:"size:8;uniqueIndex:uniq_wallet_currency"`
 	Balance   float64 `gorm:"type:decimal(18,6);default:0"`
 	Frozen    float64 `gorm:"type:decimal(18,6);default:0"`
 	UpdatedAt time.Time
@@ -42,6 +65,7 @@ type WalletLedger struct {
 	ID        uint    `gorm:"primaryKey"`
 	UserID    uint    `gorm:"uniqueIndex:uniq_ledger"`
 	Amount    float64 `gorm:"type:decimal(18,6)"`
+	Currency  string  `gorm:"size:8"`
 	Type      string  `gorm:"size:32"`
 	RefType   string  `gorm:"size:32;uniqueIndex:uniq_ledger"`
 	RefID     string  `gorm:"size:64;uniqueIndex:uniq_ledger"`
@@ -51,8 +75,10 @@ type WalletLedger struct {
 type Reward struct {
 	ID           uint      `gorm:"primaryKey"`
 	UserID       uint      `gorm:"index"`
+	CampaignID   uint      `gorm:"index;default:0"`
 	Status       string    `gorm:"size:16;index"` // pending/unlocked/expired
 	Amount       float64   `gorm:"type:decimal(18,6)"`
+	Currency     string    `gorm:"size:8;index"`
 	UnlockAmount float64   `gorm:"type:decimal(18,6)"`
 	ExpireAt     time.Time `gorm:"index"`
 	SourceType   string    `gorm:"size:32"`
@@ -64,15 +90,32 @@ type Reward struct {
 }
 
 type Task struct {
-	ID           uint    `gorm:"primaryKey"`
-	Type         string  `gorm:"size:32"`
-	Name         string  `gorm:"size:64"`
-	RewardRuleID string  `gorm:"size:64"`
-	RewardAmount float64 `gorm:"type:decimal(18,6)"`
-	Enabled      bool    `gorm:"index"`
-	CountryScope string  `gorm:"size:255"`
-	CreatedAt    time.Time
-	UpdatedAt    time.Time
+	ID            uint    `gorm:"primaryKey"`
+	CampaignID    uint    `gorm:"index;default:0"`
+	Type          string  `gorm:"size:32"`
+	Name          string  `gorm:"size:64"`
+	Description   string  `gorm:"size:255"`
+	I18n          string  `gorm:"type:text"` // {"en":{"name":"","description":""}}
+	RewardRuleID  string  `gorm:"size:64"`
+	RewardAmount  float64 `gorm:"type:decimal(18,6)"`
+	Enabled       bool    `gorm:"index"`
+	CountryScope  string  `gorm:"size:255"`
+	SortOrder     int     `gorm:"default:0"`
+	Prerequisites string  `gorm:"size:255"`  // comma separated task ids
+	UnlockRules   string  `gorm:"type:text"` // {"min_spins":1,"min_balance":0,"min_invites":0,"min_valid_invites":0}
+	VerifyParams  string  `gorm:"type:text"` // {"count":3,"valid_only":true,"statuses":["paid"]}
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+type TaskCompletion struct {
+	ID          uint   `gorm:"primaryKey"`
+	TaskID      uint   `gorm:"uniqueIndex:uniq_task_completion_ref;index:idx_task_completion_user"`
+	UserID      uint   `gorm:"index:idx_task_completion_user"`
+	Source      string `gorm:"size:32"`
+	ExternalRef string `gorm:"size:128;uniqueIndex:uniq_task_completion_ref"`
+	Payload     string `gorm:"type:text"`
+	CreatedAt   time.Time
 }
 
 type UserTaskEvent struct {
@@ -85,13 +128,64 @@ type UserTaskEvent struct {
 }
 
 type WithdrawRequest struct {
+	ID                uint       `gorm:"primaryKey"`
+	UserID            uint       `gorm:"index"`
+	Amount            float64    `gorm:"type:decimal(18,6)"` // gross, frozen from wallet
+	Currency          string     `gorm:"size:8"`
+	Fee               float64    `gorm:"type:decimal(18,6);default:0"`
+	NetAmount         float64    `gorm:"type:decimal(18,6);default:0"`
+	Status            string     `gorm:"size:16;index"` // pending/approved/rejected/paid/cancelled/expired
+	Note              string     `gorm:"size:255"`
+	PayoutAccountID   uint       `gorm:"index"`
+	PayoutMethod      string     `gorm:"size:32"`
+	PayoutProvider    string     `gorm:"size:64"`
+	PayoutAccountName string     `gorm:"size:128"`
+	PayoutAccountNo   string     `gorm:"size:128"`
+	SLABreachedAt     *time.Time `gorm:"index"` // approved but not paid within withdraw_approved_sla_hours
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+}
+
+type PayoutAccount struct {
+	ID            uint   `gorm:"primaryKey"`
+	UserID        uint   `gorm:"index"`
+	Method        string `gorm:"size:32;index:idx_payout_account_no"` // ewallet/bank/mobile_money
+	Provider      string `gorm:"size:64;index:idx_payout_account_no"`
+	AccountNo     string `gorm:"size:128;index:idx_payout_account_no"`
+	AccountName   string `gorm:"size:128"`
+	Country       string `gorm:"size:16"`
+	IsDefault     bool
+	Status        string  `gorm:"size:16;index"`        // active/removed
+	ActiveKey     *string `gorm:"size:255;uniqueIndex"` // method|provider|account_no while active, NULL once removed
+	VerifiedAt    *time.Time
+	CooldownUntil time.Time
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+type KYCSubmission struct {
+	ID         uint   `gorm:"primaryKey"`
+	UserID     uint   `gorm:"index"`
+	Country    string `gorm:"size:16"`
+	DocType    string `gorm:"size:32"`
+	DocNumber  string `gorm:"size:64"`
+	RealName   string `gorm:"size:128"`
+	Status     string `gorm:"size:16;index"` // submitted/verified/rejected
+	ReviewNote string `gorm:"size:255"`
+	ReviewedAt *time.Time
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+type PlatformLedger struct {
 	ID        uint    `gorm:"primaryKey"`
-	UserID    uint    `gorm:"index"`
+	Account   string  `gorm:"size:32;uniqueIndex:uniq_platform_ledger"`
 	Amount    float64 `gorm:"type:decimal(18,6)"`
-	Status    string  `gorm:"size:16;index"` // pending/approved/rejected/paid
-	Note      string  `gorm:"size:255"`
+	Currency  string  `gorm:"size:8"`
+	UserID    uint    `gorm:"index"`
+	RefType   string  `gorm:"size:32;uniqueIndex:uniq_platform_ledger"`
+	RefID     string  `gorm:"size:64;uniqueIndex:uniq_platform_ledger"`
 	CreatedAt time.Time
-	UpdatedAt time.Time
 }
 
 type DeviceFingerprint struct {
@@ -104,11 +198,31 @@ type DeviceFingerprint struct {
 }
 
 type RiskFlag struct {
-	ID        uint   `gorm:"primaryKey"`
-	UserID    uint   `gorm:"index"`
-	Reason    string `gorm:"size:255"`
-	Score     int
-	CreatedAt time.Time
+	ID           uint   `gorm:"primaryKey"`
+	UserID       uint   `gorm:"index"`
+	Reason       string `gorm:"size:255"`
+	Score        int
+	Source       string     `gorm:"size:16;default:manual"` // manual/auto
+	Detector     string     `gorm:"size:32;index"`
+	DedupKey     *string    `gorm:"size:128;uniqueIndex"`
+	Status       string     `gorm:"size:16;index;default:active"` // active/appealed/resolved/expired
+	ExpireAt     *time.Time `gorm:"index"`
+	ResolverNote string     `gorm:"size:255"`
+	ResolvedAt   *time.Time
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+type RiskDecision struct {
+	ID         uint   `gorm:"primaryKey"`
+	UserID     uint   `gorm:"index"`
+	Action     string `gorm:"size:16;index"`
+	Decision   string `gorm:"size:16;index"` // allow/review/deny
+	Score      int
+	Breakdown  string    `gorm:"type:text"`
+	IP         string    `gorm:"size:64;index"`
+	DeviceHash string    `gorm:"size:128;index"`
+	CreatedAt  time.Time `gorm:"index"`
 }
 
 type Blacklist struct {
@@ -119,6 +233,31 @@ type Blacklist struct {
 	CreatedAt time.Time
 }
 
+type Campaign struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	Code         string    `gorm:"size:32;uniqueIndex" json:"code"`
+	Name         string    `gorm:"size:64" json:"name"`
+	Enabled      bool      `gorm:"index" json:"enabled"`
+	StartAt      time.Time `gorm:"index" json:"start_at"`
+	EndAt        time.Time `gorm:"index" json:"end_at"`
+	CountryScope string    `gorm:"size:255" json:"country_scope"`
+	Target       float64   `gorm:"type:decimal(18,6)" json:"target"`
+	Currency     string    `gorm:"size:8" json:"currency"` // empty = user's country currency
+	WheelConfig  string    `gorm:"type:text" json:"wheel_config"`
+	Budget       float64   `gorm:"type:decimal(18,6);default:0" json:"budget"` // 0 = unlimited
+	Spent        float64   `gorm:"type:decimal(18,6);default:0" json:"spent"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+type FXRate struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Currency  string    `gorm:"size:8;uniqueIndex" json:"currency"`
+	Rate      float64   `gorm:"type:decimal(18,8)" json:"rate"` // base currency per unit
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
 type AppConfig struct {
 	ID        uint   `gorm:"primaryKey"`
 	Key       string `gorm:"size:64;uniqueIndex"`
@@ -128,19 +267,70 @@ type AppConfig struct {
 }
 
 type SpinChance struct {
-	ID        uint `gorm:"primaryKey"`
-	UserID    uint `gorm:"uniqueIndex"`
-	Count     int  `gorm:"default:0"`
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	ID         uint `gorm:"primaryKey"`
+	UserID     uint `gorm:"uniqueIndex:uniq_spin_chance"`
+	CampaignID uint `gorm:"uniqueIndex:uniq_spin_chance;default:0"`
+	Count      int  `gorm:"default:0"`
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
 }
 
 type SpinRecord struct {
 	ID           uint    `gorm:"primaryKey"`
 	UserID       uint    `gorm:"index"`
+	CampaignID   uint    `gorm:"index;default:0"`
 	Amount       float64 `gorm:"type:decimal(18,6)"`
 	PrizeType    string  `gorm:"size:32"`
 	SegmentIndex int     `gorm:"index"`
 	Status       string  `gorm:"size:16"` // win/lose
 	CreatedAt    time.Time
 }
+
+type IdempotencyRecord struct {
+	ID             uint   `gorm:"primaryKey"`
+	UserID         uint   `gorm:"uniqueIndex:uniq_idempotency"`
+	Key            string `gorm:"size:128;uniqueIndex:uniq_idempotency"`
+	Route          string `gorm:"size:128"`
+	RequestHash    string `gorm:"size:64"`
+	Status         string `gorm:"size:16"` // processing/completed
+	ResponseStatus int
+	ResponseBody   string    `gorm:"type:mediumtext"`
+	ExpireAt       time.Time `gorm:"index"`
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+type AssistSession struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	OwnerUserID   uint       `gorm:"index" json:"owner_user_id"`
+	Token         string     `gorm:"size:32;uniqueIndex" json:"token"`
+	Goal          int        `json:"goal"`
+	HelperCount   int        `gorm:"default:0" json:"helper_count"`
+	RewardType    string     `gorm:"size:16" json:"reward_type"` // spin/cash
+	RewardPerHelp float64    `gorm:"type:decimal(18,6)" json:"reward_per_help"`
+	GoalBonus     float64    `gorm:"type:decimal(18,6)" json:"goal_bonus"`
+	Status        string     `gorm:"size:16;index" json:"status"` // open/completed/expired
+	ExpireAt      time.Time  `gorm:"index" json:"expire_at"`
+	CompletedAt   *time.Time `json:"completed_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+type AssistHelp struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	SessionID    uint      `gorm:"uniqueIndex:uniq_assist_helper" json:"session_id"`
+	HelperUserID uint      `gorm:"uniqueIndex:uniq_assist_helper;index" json:"helper_user_id"`
+	OwnerUserID  uint      `gorm:"index" json:"owner_user_id"`
+	IP           string    `gorm:"size:64" json:"-"`
+	DeviceHash   string    `gorm:"size:128" json:"-"`
+	Reward       float64   `gorm:"type:decimal(18,6)" json:"reward"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type LeaderboardSettlement struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	PeriodType string    `gorm:"size:16;uniqueIndex:uniq_leaderboard_period" json:"period_type"` // daily/weekly
+	PeriodKey  string    `gorm:"size:32;uniqueIndex:uniq_leaderboard_period" json:"period_key"`
+	Standings  string    `gorm:"type:text" json:"standings"`
+	CreatedAt  time.Time `json:"created_at"`
+}
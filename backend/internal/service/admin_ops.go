@@ -17,8 +17,10 @@ type AdminDashboard struct {
 	NewUsersToday      int64   `json:"new_users_today"`
 	PendingWithdraws   int64   `json:"pending_withdraws"`
 	PendingWithdrawAmt float64 `json:"pending_withdraw_amount"`
+	StaleApproved      int64   `json:"stale_approved_withdraws"`
 	RewardsPendingAmt  float64 `json:"rewards_pending_amount"`
 	RewardsUnlockedAmt float64 `json:"rewards_unlocked_amount"`
+	WithdrawFeeRevenue float64 `json:"withdraw_fee_revenue"`
 }
 
 func NewAdminOpsService(db *gorm.DB) *AdminOpsService {
@@ -42,11 +44,17 @@ func (s *AdminOpsService) Dashboard() (AdminDashboard, error) {
 	if err := s.db.Model(&models.WithdrawRequest{}).Where("status = ?", "pending").Select("COALESCE(SUM(amount),0)").Scan(&out.PendingWithdrawAmt).Error; err != nil {
 		return out, err
 	}
+	if err := s.db.Model(&models.WithdrawRequest{}).Where("status = ? AND sla_breached_at IS NOT NULL", "approved").Count(&out.StaleApproved).Error; err != nil {
+		return out, err
+	}
 	if err := s.db.Model(&models.Reward{}).Where("status = ?", "pending").Select("COALESCE(SUM(amount),0)").Scan(&out.RewardsPendingAmt).Error; err != nil {
 		return out, err
 	}
 	if err := s.db.Model(&models.Reward{}).Where("status = ?", "unlocked").Select("COALESCE(SUM(amount),0)").Scan(&out.RewardsUnlockedAmt).Error; err != nil {
 		return out, err
 	}
+	if err := s.db.Model(&models.PlatformLedger{}).Where("account = ?", "withdraw_fee").Select("COALESCE(SUM(amount),0)").Scan(&out.WithdrawFeeRevenue).Error; err != nil {
+		return out, err
+	}
 	return out, nil
 }
@@ -0,0 +1,369 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"red_packet/backend/internal/models"
+)
+
+type AssistConfig struct {
+	Enabled            bool    `json:"enabled"`
+	Goal               int     `json:"goal"`
+	ExpireHours        float64 `json:"expire_hours"`
+	RewardType         string  `json:"reward_type"`
+	RewardPerHelp      float64 `json:"reward_per_help"`
+	GoalBonus          float64 `json:"goal_bonus"`
+	SessionsPerDay     int     `json:"sessions_per_day"`
+	HelperDailyMax     int     `json:"helper_daily_max"`
+	NewHelperOnly      bool    `json:"new_helper_only"`
+	RejectSharedDevice bool    `json:"reject_shared_device"`
+	RejectSharedIP     bool    `json:"reject_shared_ip"`
+}
+
+type AssistSessionView struct {
+	models.AssistSession
+	Owner   string             `json:"owner"`
+	Helpers []AssistHelperView `json:"helpers"`
+}
+
+type AssistHelperView struct {
+	Account   string    `json:"account"`
+	Reward    float64   `json:"reward"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type AssistHelpResult struct {
+	SessionID   uint    `json:"session_id"`
+	HelperCount int     `json:"helper_count"`
+	Goal        int     `json:"goal"`
+	Completed   bool    `json:"completed"`
+	Reward      float64 `json:"reward"`
+}
+
+const (
+	AssistRewardSpin = "spin"
+	AssistRewardCash = "cash"
+)
+
+var defaultAssistConfig = AssistConfig{
+	Enabled:            true,
+	Goal:               5,
+	ExpireHours:        24,
+	RewardType:         AssistRewardSpin,
+	RewardPerHelp:      1,
+	GoalBonus:          3,
+	SessionsPerDay:     3,
+	HelperDailyMax:     3,
+	RejectSharedDevice: true,
+	RejectSharedIP:     true,
+}
+
+type AssistService struct {
+	db         *gorm.DB
+	lotterySvc *LotteryService
+	rewardSvc  *RewardService
+	riskSvc    *RiskService
+}
+
+func NewAssistService(db *gorm.DB, lotterySvc *LotteryService, rewardSvc *RewardService, riskSvc *RiskService) *AssistService {
+	return &AssistService{db: db, lotterySvc: lotterySvc, rewardSvc: rewardSvc, riskSvc: riskSvc}
+}
+
+func (s *AssistService) Config() AssistConfig {
+	cfg := defaultAssistConfig
+	_ = jsonUnmarshal(loadConfigString(s.db, "assist_config", ""), &cfg)
+	if cfg.Goal < 1 {
+		cfg.Goal = 1
+	}
+	if cfg.RewardType != AssistRewardCash {
+		cfg.RewardType = AssistRewardSpin
+	}
+	return cfg
+}
+
+func (s *AssistService) Open(userID uint) (AssistSessionView, error) {
+	cfg := s.Config()
+	if !cfg.Enabled {
+		return AssistSessionView{}, ErrAssistDisabled
+	}
+	now := time.Now()
+	var session models.AssistSession
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var owner models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&owner, userID).Error; err != nil {
+			return err
+		}
+		err := tx.Where("owner_user_id = ? AND status = ? AND expire_at > ?", userID, "open", now).
+			Order("id DESC").First(&session).Error
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if cfg.SessionsPerDay > 0 {
+			var opened int64
+			if err := tx.Model(&models.AssistSession{}).
+				Where("owner_user_id = ? AND created_at >= ?", userID, now.Add(-24*time.Hour)).
+				Count(&opened).Error; err != nil {
+				return err
+			}
+			if opened >= int64(cfg.SessionsPerDay) {
+				return ErrAssistSessionLimit
+			}
+		}
+		token, err := randomHex(16)
+		if err != nil {
+			return err
+		}
+		session = models.AssistSession{
+			OwnerUserID:   userID,
+			Token:         token,
+			Goal:          cfg.Goal,
+			RewardType:    cfg.RewardType,
+			RewardPerHelp: cfg.RewardPerHelp,
+			GoalBonus:     cfg.GoalBonus,
+			Status:        "open",
+			ExpireAt:      now.Add(time.Duration(cfg.ExpireHours * float64(time.Hour))),
+		}
+		return tx.Create(&session).Error
+	})
+	if err != nil {
+		return AssistSessionView{}, err
+	}
+	return s.view(session)
+}
+
+func (s *AssistService) Current(userID uint) (AssistSessionView, error) {
+	var session models.AssistSession
+	if err := s.db.Where("owner_user_id = ?", userID).Order("id DESC").First(&session).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return AssistSessionView{}, ErrAssistNotFound
+		}
+		return AssistSessionView{}, err
+	}
+	return s.view(session)
+}
+
+func (s *AssistService) Get(token string) (AssistSessionView, error) {
+	var session models.AssistSession
+	if err := s.db.Where("token = ?", token).First(&session).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return AssistSessionView{}, ErrAssistNotFound
+		}
+		return AssistSessionView{}, err
+	}
+	return s.view(session)
+}
+
+func (s *AssistService) Help(helperID uint, token string, meta RequestMeta) (AssistHelpResult, error) {
+	cfg := s.Config()
+	if !cfg.Enabled {
+		return AssistHelpResult{}, ErrAssistDisabled
+	}
+	if _, err := s.riskSvc.Check(helperID, RiskActionAssist, meta); err != nil {
+		return AssistHelpResult{}, err
+	}
+
+	now := time.Now()
+	var result AssistHelpResult
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var session models.AssistSession
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("token = ?", token).First(&session).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrAssistNotFound
+			}
+			return err
+		}
+		if session.Status != "open" || !session.ExpireAt.After(now) {
+			return ErrAssistClosed
+		}
+		if session.OwnerUserID == helperID {
+			return ErrAssistSelf
+		}
+		if err := s.checkHelper(tx, cfg, session, helperID, meta, now); err != nil {
+			return err
+		}
+
+		help := models.AssistHelp{
+			SessionID:    session.ID,
+			HelperUserID: helperID,
+			OwnerUserID:  session.OwnerUserID,
+			IP:           meta.IP,
+			DeviceHash:   meta.DeviceHash,
+			Reward:       session.RewardPerHelp,
+		}
+		if err := tx.Create(&help).Error; err != nil {
+			if isDuplicate(err) {
+				return ErrAssistAlreadyHelped
+			}
+			return err
+		}
+		if err := s.grant(tx, session, session.RewardPerHelp, "assist_help", fmt.Sprintf("%d:%d", session.ID, helperID)); err != nil {
+			return err
+		}
+
+		updates := map[string]interface{}{"helper_count": gorm.Expr("helper_count + 1")}
+		session.HelperCount++
+		if session.HelperCount >= session.Goal {
+			updates["status"] = "completed"
+			updates["completed_at"] = &now
+			if err := s.grant(tx, session, session.GoalBonus, "assist_goal", fmt.Sprintf("%d", session.ID)); err != nil {
+				return err
+			}
+		}
+		if err := tx.Model(&models.AssistSession{}).Where("id = ?", session.ID).Updates(updates).Error; err != nil {
+			return err
+		}
+		result = AssistHelpResult{
+			SessionID:   session.ID,
+			HelperCount: session.HelperCount,
+			Goal:        session.Goal,
+			Completed:   session.HelperCount >= session.Goal,
+			Reward:      session.RewardPerHelp,
+		}
+		return nil
+	})
+	return result, err
+}
+
+func (s *AssistService) checkHelper(tx *gorm.DB, cfg AssistConfig, session models.AssistSession, helperID uint, meta RequestMeta, now time.Time) error {
+	var users []models.User
+	if err := tx.Where("id IN ?", []uint{helperID, session.OwnerUserID}).Find(&users).Error; err != nil {
+		return err
+	}
+	var helper, owner models.User
+	for _, u := range users {
+		if u.ID == helperID {
+			helper = u
+		} else {
+			owner = u
+		}
+	}
+	if helper.ID == 0 || helper.Restricted {
+		return ErrAssistRestricted
+	}
+	if cfg.NewHelperOnly && helper.CreatedAt.Before(session.CreatedAt) {
+		return ErrAssistRestricted
+	}
+	if cfg.RejectSharedDevice {
+		device := meta.DeviceHash
+		if device == "" {
+			device = helper.DeviceHash
+		}
+		if device != "" && device == owner.DeviceHash {
+			return ErrAssistSharedEnv
+		}
+	}
+	if cfg.RejectSharedIP && meta.IP != "" {
+		if meta.IP == owner.RegisterIP {
+			return ErrAssistSharedEnv
+		}
+		var sameIP int64
+		if err := tx.Model(&models.AssistHelp{}).
+			Where("session_id = ? AND ip = ?", session.ID, meta.IP).
+			Count(&sameIP).Error; err != nil {
+			return err
+		}
+		if sameIP > 0 {
+			return ErrAssistSharedEnv
+		}
+	}
+	if cfg.HelperDailyMax > 0 {
+		var helped int64
+		if err := tx.Model(&models.AssistHelp{}).
+			Where("helper_user_id = ? AND created_at >= ?", helperID, now.Add(-24*time.Hour)).
+			Count(&helped).Error; err != nil {
+			return err
+		}
+		if helped >= int64(cfg.HelperDailyMax) {
+			return ErrAssistHelperLimit
+		}
+	}
+	return nil
+}
+
+func (s *AssistService) grant(tx *gorm.DB, session models.AssistSession, amount float64, refType, refID string) error {
+	if amount <= 0 {
+		return nil
+	}
+	if session.RewardType == AssistRewardCash {
+		_, err := s.rewardSvc.GrantReward(tx, session.OwnerUserID, amount, refType, refID, "pending")
+		return err
+	}
+	_, err := s.lotterySvc.AddChancesTx(tx, session.OwnerUserID, int(math.Round(amount)))
+	return err
+}
+
+func (s *AssistService) ExpireSessions(now time.Time) (int, error) {
+	res := s.db.Model(&models.AssistSession{}).
+		Where("status = ? AND expire_at <= ?", "open", now).
+		Update("status", "expired")
+	return int(res.RowsAffected), res.Error
+}
+
+func (s *AssistService) ListSessions(userID uint, status string, page, size int) ([]models.AssistSession, error) {
+	if page < 1 {
+		page = 1
+	}
+	if size < 1 || size > 100 {
+		size = 20
+	}
+	q := s.db.Model(&models.AssistSession{})
+	if userID > 0 {
+		q = q.Where("owner_user_id = ?", userID)
+	}
+	if status != "" {
+		q = q.Where("status = ?", status)
+	}
+	var items []models.AssistSession
+	err := q.Order("id DESC").Offset((page - 1) * size).Limit(size).Find(&items).Error
+	return items, err
+}
+
+func (s *AssistService) view(session models.AssistSession) (AssistSessionView, error) {
+	view := AssistSessionView{AssistSession: session, Helpers: []AssistHelperView{}}
+	var owner models.User
+	if err := s.db.First(&owner, session.OwnerUserID).Error; err == nil {
+		view.Owner = maskUserAccount(owner)
+	}
+	var helps []models.AssistHelp
+	if err := s.db.Where("session_id = ?", session.ID).Order("id").Find(&helps).Error; err != nil {
+		return view, err
+	}
+	if len(helps) == 0 {
+		return view, nil
+	}
+	ids := make([]uint, 0, len(helps))
+	for _, h := range helps {
+		ids = append(ids, h.HelperUserID)
+	}
+	var users []models.User
+	if err := s.db.Where("id IN ?", ids).Find(&users).Error; err != nil {
+		return view, err
+	}
+	accounts := make(map[uint]string, len(users))
+	for _, u := range users {
+		accounts[u.ID] = maskUserAccount(u)
+	}
+	for _, h := range helps {
+		view.Helpers = append(view.Helpers, AssistHelperView{Account: accounts[h.HelperUserID], Reward: h.Reward, CreatedAt: h.CreatedAt})
+	}
+	return view, nil
+}
+
+func randomHex(n int) (string, error) {
+	raw := make([]byte, n)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(raw), nil
+}
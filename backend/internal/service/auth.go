@@ -1,7 +1,8 @@
 package service
 
 import (
-	"fmt"
+	"errors"
+	"log"
 	"strings"
 	"time"
 
@@ -18,64 +19,46 @@ type LoginInput struct {
 	DeviceHash string `json:"device_hash"`
 	Country    string `json:"country"`
 	Language   string `json:"language"`
+
+	AttributionToken string `json:"attribution_token"`
 }
 
 type AuthService struct {
-	db  *gorm.DB
-	cfg config.Config
+	db          *gorm.DB
+	cfg         config.Config
+	riskSvc     *RiskService
+	referralSvc *ReferralService
 }
 
-func NewAuthService(db *gorm.DB, cfg config.Config) *AuthService {
-	return &AuthService{db: db, cfg: cfg}
+func NewAuthService(db *gorm.DB, cfg config.Config, riskSvc *RiskService, referralSvc *ReferralService) *AuthService {
+	return &AuthService{db: db, cfg: cfg, riskSvc: riskSvc, referralSvc: referralSvc}
 }
 
-func (s *AuthService) Login(in LoginInput) (string, models.User, error) {
-	var user models.User
-	q := s.db.Model(&models.User{})
-	if in.Phone != "" {
-		q = q.Where("phone = ?", in.Phone)
-	} else if in.Email != "" {
-		q = q.Where("email = ?", in.Email)
+func (s *AuthService) Login(in LoginInput, meta RequestMeta) (string, models.User, error) {
+	in.Phone = strings.TrimSpace(in.Phone)
+	in.Email = strings.ToLower(strings.TrimSpace(in.Email))
+	if in.Phone == "" && in.Email == "" {
+		return "", models.User{}, ErrAccountRequired
+	}
+	if in.DeviceHash == "" {
+		in.DeviceHash = meta.DeviceHash
+	}
+	if meta.DeviceHash == "" {
+		meta.DeviceHash = in.DeviceHash
 	}
 
-	err := q.First(&user).Error
+	// New accounts are risk-checked inside findOrRegister before anything is
+	// written; existing accounts get the regular login check.
+	user, created, err := s.findOrRegister(in, meta)
 	if err != nil {
-		if err != gorm.ErrRecordNotFound {
-			return "", user, err
-		}
-		user = models.User{
-			Phone:      in.Phone,
-			Email:      in.Email,
-			DeviceHash: in.DeviceHash,
-			Country:    in.Country,
-			Language:   in.Language,
-		}
-		if err := s.db.Create(&user).Error; err != nil {
-			return "", user, err
-		}
-		rc := models.ReferralCode{
-			UserID: user.ID,
-			Code:   fmt.Sprintf("U%06d", user.ID),
-		}
-		if err := s.db.Create(&rc).Error; err != nil {
-			return "", user, err
-		}
-		wallet := models.Wallet{UserID: user.ID}
-	if err := s.db.Create(&wallet).Error; err != nil {
 		return "", user, err
 	}
-	}
-
-	var chance models.SpinChance
-	if err := s.db.Where("user_id = ?", user.ID).First(&chance).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			chance = models.SpinChance{UserID: user.ID, Count: 100}
-			if err := s.db.Create(&chance).Error; err != nil {
-				return "", user, err
-			}
-		} else {
-			return "", user, err
+	if created {
+		if _, err := s.referralSvc.AttributeRegistration(user.ID, strings.TrimSpace(in.AttributionToken), meta); err != nil {
+			log.Printf("referral attribution for user %d: %v", user.ID, err)
 		}
+	} else if _, err := s.riskSvc.Check(user.ID, RiskActionLogin, meta); err != nil {
+		return "", user, err
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
@@ -89,6 +72,135 @@ func (s *AuthService) Login(in LoginInput) (string, models.User, error) {
 	return tokenStr, user, nil
 }
 
+func (s *AuthService) findUser(in LoginInput) (models.User, error) {
+	var user models.User
+	q := s.db.Model(&models.User{})
+	if in.Phone != "" {
+		q = q.Where("phone = ?", in.Phone)
+	} else {
+		q = q.Where("email = ?", in.Email)
+	}
+	err := q.First(&user).Error
+	return user, err
+}
+
+func (s *AuthService) findOrRegister(in LoginInput, meta RequestMeta) (models.User, bool, error) {
+	user, err := s.findUser(in)
+	if err == nil {
+		return user, false, s.ensureSpinChance(user)
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return user, false, err
+	}
+
+	user = models.User{
+		Phone:      optionalString(in.Phone),
+		Email:      optionalString(in.Email),
+		DeviceHash: in.DeviceHash,
+		Country:    in.Country,
+		Language:   in.Language,
+		Currency:   currencyForCountry(s.db, in.Country),
+		RegisterIP: meta.IP,
+	}
+	if _, err := s.riskSvc.CheckRegistration(user, meta); err != nil {
+		return models.User{}, false, err
+	}
+	restricted, err := s.checkRegisterLimit(in.DeviceHash, meta.IP, time.Now())
+	if err != nil {
+		return models.User{}, false, err
+	}
+	user.Restricted = restricted
+	welcome := loadConfigInt(s.db, "register_welcome_spins", 100)
+	if restricted {
+		welcome = 0
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		if _, err := issueReferralCode(tx, user.ID, "generated", ""); err != nil {
+			return err
+		}
+		if err := tx.Create(&models.Wallet{UserID: user.ID, Currency: user.Currency}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.SpinChance{UserID: user.ID, Count: welcome}).Error
+	})
+	if err == nil {
+		return user, true, nil
+	}
+	if !isDuplicate(err) {
+		return models.User{}, false, err
+	}
+	existing, findErr := s.findUser(in)
+	if errors.Is(findErr, gorm.ErrRecordNotFound) {
+		return models.User{}, false, ErrAccountTaken
+	}
+	return existing, false, findErr
+}
+
+func (s *AuthService) ensureSpinChance(user models.User) error {
+	var count int64
+	if err := s.db.Model(&models.SpinChance{}).Where("user_id = ? AND campaign_id = 0", user.ID).Count(&count).Error; err != nil || count > 0 {
+		return err
+	}
+	welcome := loadConfigInt(s.db, "register_welcome_spins", 100)
+	if user.Restricted {
+		welcome = 0
+	}
+	if err := s.db.Create(&models.SpinChance{UserID: user.ID, Count: welcome}).Error; err != nil && !isDuplicate(err) {
+		return err
+	}
+	return nil
+}
+
+func derefString(v *string) string {
+	if v == nil {
+		return ""
+	}
+	return *v
+}
+
+func optionalString(v string) *string {
+	if v == "" {
+		return nil
+	}
+	return &v
+}
+
+func (s *AuthService) checkRegisterLimit(deviceHash, ip string, now time.Time) (bool, error) {
+	since := now.Add(-time.Duration(loadConfigFloat(s.db, "register_window_hours", 24) * float64(time.Hour)))
+	over := false
+	checks := []struct {
+		column string
+		value  string
+		key    string
+	}{
+		{"device_hash", deviceHash, "register_device_max"},
+		{"register_ip", ip, "register_ip_max"},
+	}
+	for _, c := range checks {
+		max := loadConfigInt(s.db, c.key, 0)
+		if c.value == "" || max <= 0 {
+			continue
+		}
+		var count int64
+		if err := s.db.Model(&models.User{}).Where(c.column+" = ? AND created_at >= ?", c.value, since).Count(&count).Error; err != nil {
+			return false, err
+		}
+		if count >= int64(max) {
+			over = true
+		}
+	}
+	if !over {
+		return false, nil
+	}
+	if loadConfigString(s.db, "register_limit_mode", "restrict") == "block" {
+		return false, ErrRegisterLimited
+	}
+	return true, nil
+}
+
 func (s *AuthService) OTP(_ string) string {
 	return strings.Repeat("*", 6)
 }
@@ -0,0 +1,170 @@
+package service
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"red_packet/backend/internal/config"
+	"red_packet/backend/internal/database"
+	"red_packet/backend/internal/models"
+)
+
+// openTestDB connects to the MySQL instance in TEST_MYSQL_DSN; the schema is
+// migrated and seeded exactly like production startup.
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_MYSQL_DSN")
+	if dsn == "" {
+		t.Skip("TEST_MYSQL_DSN not set; see README 测试 for running the MySQL-backed tests")
+	}
+	var cfg config.Config
+	cfg.MySQL.DSN = dsn
+	db, err := database.Init(cfg)
+	if err != nil {
+		t.Fatalf("init db: %v", err)
+	}
+	return db
+}
+
+func newTestContainer(db *gorm.DB) *Container {
+	var cfg config.Config
+	cfg.JWT.Secret = "test-secret"
+	cfg.JWT.TTLHours = 1
+	return NewContainer(db, cfg)
+}
+
+func newTestAuthService(db *gorm.DB) *AuthService {
+	return newTestContainer(db).Auth
+}
+
+func assertSingleAccount(t *testing.T, db *gorm.DB, column, value string, ids []uint) {
+	t.Helper()
+	for i, id := range ids {
+		if id == 0 || id != ids[0] {
+			t.Fatalf("worker %d got user %d, want %d", i, id, ids[0])
+		}
+	}
+	userID := ids[0]
+	counts := []struct {
+		name  string
+		query *gorm.DB
+	}{
+		{"users", db.Model(&models.User{}).Where(column+" = ?", value)},
+		{"wallets", db.Model(&models.Wallet{}).Where("user_id = ?", userID)},
+		{"referral codes", db.Model(&models.ReferralCode{}).Where("user_id = ?", userID)},
+		{"welcome spin rows", db.Model(&models.SpinChance{}).Where("user_id = ? AND campaign_id = 0", userID)},
+	}
+	for _, c := range counts {
+		var n int64
+		if err := c.query.Count(&n).Error; err != nil {
+			t.Fatalf("count %s: %v", c.name, err)
+		}
+		if n != 1 {
+			t.Errorf("%s = %d, want 1", c.name, n)
+		}
+	}
+}
+
+func runConcurrently(t *testing.T, workers int, fn func(i int) (uint, error)) []uint {
+	t.Helper()
+	ids := make([]uint, workers)
+	errs := make([]error, workers)
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			ids[i], errs[i] = fn(i)
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("worker %d: %v", i, err)
+		}
+	}
+	return ids
+}
+
+func TestLoginConcurrentFirstLoginSamePhone(t *testing.T) {
+	db := openTestDB(t)
+	svc := newTestAuthService(db)
+	phone := fmt.Sprintf("+62%d", time.Now().UnixNano()%1e10)
+
+	ids := runConcurrently(t, 16, func(i int) (uint, error) {
+		_, user, err := svc.Login(LoginInput{Phone: phone, DeviceHash: "dev-phone"}, RequestMeta{IP: "10.0.0.1"})
+		return user.ID, err
+	})
+	assertSingleAccount(t, db, "phone", phone, ids)
+}
+
+func TestLoginConcurrentFirstLoginSameEmail(t *testing.T) {
+	db := openTestDB(t)
+	svc := newTestAuthService(db)
+	email := fmt.Sprintf("race-%d@example.com", time.Now().UnixNano())
+
+	ids := runConcurrently(t, 16, func(i int) (uint, error) {
+		// Mixed case must still resolve to the single lower-cased account.
+		in := LoginInput{Email: email, DeviceHash: "dev-email"}
+		if i%2 == 1 {
+			in.Email = " " + strings.ToUpper(email) + " "
+		}
+		_, user, err := svc.Login(in, RequestMeta{IP: "10.0.0.2"})
+		return user.ID, err
+	})
+	assertSingleAccount(t, db, "email", email, ids)
+}
+
+func TestFindOrRegisterConcurrent(t *testing.T) {
+	db := openTestDB(t)
+	svc := newTestAuthService(db)
+	email := fmt.Sprintf("register-%d@example.com", time.Now().UnixNano())
+
+	var mu sync.Mutex
+	created := 0
+	ids := runConcurrently(t, 16, func(i int) (uint, error) {
+		user, isNew, err := svc.findOrRegister(LoginInput{Email: email}, RequestMeta{IP: "10.0.0.3"})
+		if isNew {
+			mu.Lock()
+			created++
+			mu.Unlock()
+		}
+		return user.ID, err
+	})
+	if created != 1 {
+		t.Errorf("created = %d, want 1", created)
+	}
+	assertSingleAccount(t, db, "email", email, ids)
+}
+
+func TestLoginDeniedDeviceCreatesNoAccount(t *testing.T) {
+	db := openTestDB(t)
+	svc := newTestAuthService(db)
+	device := fmt.Sprintf("blocked-%d", time.Now().UnixNano())
+	email := "denied-" + device + "@example.com"
+	if err := db.Create(&models.Blacklist{Type: "device_hash", Value: device}).Error; err != nil {
+		t.Fatalf("blacklist device: %v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, _, err := svc.Login(LoginInput{Email: email, DeviceHash: device}, RequestMeta{IP: "10.0.0.4"}); err != ErrRiskCheckFailed {
+			t.Fatalf("attempt %d: err = %v, want %v", i, err, ErrRiskCheckFailed)
+		}
+	}
+	var count int64
+	if err := db.Model(&models.User{}).Where("email = ? OR device_hash = ?", email, device).Count(&count).Error; err != nil {
+		t.Fatalf("count users: %v", err)
+	}
+	if count != 0 {
+		t.Errorf("users = %d, want 0", count)
+	}
+}
@@ -0,0 +1,206 @@
+package service
+
+import (
+	"errors"
+	"math/rand"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"red_packet/backend/internal/models"
+)
+
+type CampaignPrize struct {
+	Type   string  `json:"type"`
+	Weight float64 `json:"weight"`
+	Min    float64 `json:"min"`
+	Max    float64 `json:"max"`
+}
+
+type CampaignView struct {
+	models.Campaign
+	Tasks []models.Task `json:"tasks"`
+}
+
+type CampaignService struct {
+	db *gorm.DB
+}
+
+func NewCampaignService(db *gorm.DB) *CampaignService {
+	return &CampaignService{db: db}
+}
+
+func (s *CampaignService) Active(country string, now time.Time) ([]CampaignView, error) {
+	var campaigns []models.Campaign
+	if err := s.db.Where("enabled = ? AND start_at <= ? AND end_at > ?", true, now, now).
+		Order("start_at ASC, id ASC").Find(&campaigns).Error; err != nil {
+		return nil, err
+	}
+	out := make([]CampaignView, 0, len(campaigns))
+	ids := make([]uint, 0, len(campaigns))
+	for _, c := range campaigns {
+		if country != "" && !countryInScope(c.CountryScope, country) {
+			continue
+		}
+		out = append(out, CampaignView{Campaign: c, Tasks: []models.Task{}})
+		ids = append(ids, c.ID)
+	}
+	if len(ids) == 0 {
+		return out, nil
+	}
+	var tasks []models.Task
+	if err := s.db.Where("enabled = ? AND campaign_id IN ?", true, ids).Order("id ASC").Find(&tasks).Error; err != nil {
+		return nil, err
+	}
+	for i := range out {
+		for _, t := range tasks {
+			if t.CampaignID == out[i].ID {
+				out[i].Tasks = append(out[i].Tasks, t)
+			}
+		}
+	}
+	return out, nil
+}
+
+func (s *CampaignService) List() ([]models.Campaign, error) {
+	var items []models.Campaign
+	if err := s.db.Order("id DESC").Find(&items).Error; err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func (s *CampaignService) Save(in models.Campaign) (models.Campaign, error) {
+	in.Code = strings.TrimSpace(in.Code)
+	in.Name = strings.TrimSpace(in.Name)
+	in.CountryScope = normalizeCountryScope(in.CountryScope)
+	in.Currency = normalizeCurrency(in.Currency)
+	if in.Code == "" || in.StartAt.IsZero() || !in.EndAt.After(in.StartAt) || in.Target < 0 || in.Budget < 0 {
+		return models.Campaign{}, ErrCampaignInvalid
+	}
+	if in.Name == "" {
+		in.Name = in.Code
+	}
+	if strings.TrimSpace(in.WheelConfig) != "" {
+		if _, err := parseCampaignWheel(in.WheelConfig); err != nil {
+			return models.Campaign{}, ErrCampaignInvalid
+		}
+	}
+	if in.ID == 0 {
+		in.Spent = 0
+		if err := s.db.Create(&in).Error; err != nil {
+			if isDuplicate(err) {
+				return models.Campaign{}, ErrCampaignInvalid
+			}
+			return models.Campaign{}, err
+		}
+		return in, nil
+	}
+	res := s.db.Model(&models.Campaign{}).Where("id = ?", in.ID).Updates(map[string]interface{}{
+		"code":          in.Code,
+		"name":          in.Name,
+		"enabled":       in.Enabled,
+		"start_at":      in.StartAt,
+		"end_at":        in.EndAt,
+		"country_scope": in.CountryScope,
+		"target":        in.Target,
+		"currency":      in.Currency,
+		"wheel_config":  in.WheelConfig,
+		"budget":        in.Budget,
+	})
+	if res.Error != nil {
+		if isDuplicate(res.Error) {
+			return models.Campaign{}, ErrCampaignInvalid
+		}
+		return models.Campaign{}, res.Error
+	}
+	var out models.Campaign
+	if err := s.db.First(&out, in.ID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return models.Campaign{}, ErrCampaignNotFound
+		}
+		return models.Campaign{}, err
+	}
+	return out, nil
+}
+
+// userCountry resolves the country used for scope checks: the profile
+// country, falling back to the request IP.
+func userCountry(db *gorm.DB, geoIP *GeoIP, userID uint, meta RequestMeta) (models.User, string, error) {
+	var user models.User
+	if err := db.Select("id", "country", "language").First(&user, userID).Error; err != nil {
+		return user, "", err
+	}
+	country := strings.ToUpper(strings.TrimSpace(user.Country))
+	if country == "" {
+		country = geoIP.Lookup(meta.IP)
+	}
+	return user, country, nil
+}
+
+func loadActiveCampaign(tx *gorm.DB, campaignID uint, country string, now time.Time) (models.Campaign, error) {
+	if campaignID == 0 {
+		return models.Campaign{}, nil
+	}
+	var campaign models.Campaign
+	if err := tx.First(&campaign, campaignID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return campaign, ErrCampaignNotFound
+		}
+		return campaign, err
+	}
+	if !campaign.Enabled || now.Before(campaign.StartAt) || !now.Before(campaign.EndAt) {
+		return campaign, ErrCampaignInactive
+	}
+	if !countryInScope(campaign.CountryScope, country) {
+		return campaign, ErrCampaignOutOfScope
+	}
+	return campaign, nil
+}
+
+func reserveCampaignBudget(tx *gorm.DB, campaign models.Campaign, amount float64) (bool, error) {
+	q := tx.Model(&models.Campaign{}).Where("id = ?", campaign.ID)
+	if campaign.Budget > 0 {
+		q = q.Where("spent + ? <= budget", amount)
+	}
+	res := q.Update("spent", gorm.Expr("spent + ?", amount))
+	return res.RowsAffected > 0, res.Error
+}
+
+func parseCampaignWheel(raw string) ([]CampaignPrize, error) {
+	var prizes []CampaignPrize
+	if err := jsonUnmarshal(raw, &prizes); err != nil {
+		return nil, err
+	}
+	total := 0.0
+	for _, p := range prizes {
+		if p.Weight < 0 || p.Min < 0 || p.Max < p.Min {
+			return nil, ErrCampaignInvalid
+		}
+		total += p.Weight
+	}
+	if total <= 0 {
+		return nil, ErrCampaignInvalid
+	}
+	return prizes, nil
+}
+
+func drawCampaignPrize(prizes []CampaignPrize) (float64, string, int) {
+	total := 0.0
+	for _, p := range prizes {
+		total += p.Weight
+	}
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	pick := r.Float64() * total
+	for i, p := range prizes {
+		if pick < p.Weight || i == len(prizes)-1 {
+			if p.Max <= 0 {
+				return 0, p.Type, i
+			}
+			return randRange(r, p.Min, p.Max), p.Type, i
+		}
+		pick -= p.Weight
+	}
+	return 0, "thanks", 0
+}
@@ -1,6 +1,10 @@
 package service
 
 import (
+	"strconv"
+	"strings"
+	"time"
+
 	"gorm.io/gorm"
 
 	"red_packet/backend/internal/models"
@@ -10,19 +14,21 @@ type BootstrapConfig struct {
 	Tasks       []models.Task     `json:"tasks"`
 	RewardTiers string            `json:"reward_tiers"`
 	Configs     map[string]string `json:"configs"`
+	Campaigns   []CampaignView    `json:"campaigns"`
 }
 
 type ConfigService struct {
-	db *gorm.DB
+	db          *gorm.DB
+	campaignSvc *CampaignService
 }
 
-func NewConfigService(db *gorm.DB) *ConfigService {
-	return &ConfigService{db: db}
+func NewConfigService(db *gorm.DB, campaignSvc *CampaignService) *ConfigService {
+	return &ConfigService{db: db, campaignSvc: campaignSvc}
 }
 
-func (s *ConfigService) Bootstrap() (BootstrapConfig, error) {
+func (s *ConfigService) Bootstrap(country string) (BootstrapConfig, error) {
 	var tasks []models.Task
-	if err := s.db.Where("enabled = ?", true).Order("id ASC").Find(&tasks).Error; err != nil {
+	if err := s.db.Where("enabled = ? AND campaign_id = 0", true).Order("id ASC").Find(&tasks).Error; err != nil {
 		return BootstrapConfig{}, err
 	}
 	var configs []models.AppConfig
@@ -37,7 +43,11 @@ func (s *ConfigService) Bootstrap() (BootstrapConfig, error) {
 			rewardTiers = c.Value
 		}
 	}
-	return BootstrapConfig{Tasks: tasks, RewardTiers: rewardTiers, Configs: m}, nil
+	campaigns, err := s.campaignSvc.Active(country, time.Now())
+	if err != nil {
+		return BootstrapConfig{}, err
+	}
+	return BootstrapConfig{Tasks: tasks, RewardTiers: rewardTiers, Configs: m, Campaigns: campaigns}, nil
 }
 
 func (s *ConfigService) List() ([]models.AppConfig, error) {
@@ -66,3 +76,38 @@ func (s *ConfigService) Upsert(key, value string) (models.AppConfig, error) {
 	}
 	return cfg, nil
 }
+
+func loadConfigString(db *gorm.DB, key, def string) string {
+	var cfg models.AppConfig
+	if err := db.Where("`key` = ?", key).First(&cfg).Error; err != nil {
+		return def
+	}
+	if strings.TrimSpace(cfg.Value) == "" {
+		return def
+	}
+	return strings.TrimSpace(cfg.Value)
+}
+
+func loadConfigFloat(db *gorm.DB, key string, def float64) float64 {
+	if v, err := strconv.ParseFloat(loadConfigString(db, key, ""), 64); err == nil {
+		return v
+	}
+	return def
+}
+
+func loadConfigInt(db *gorm.DB, key string, def int) int {
+	if v, err := strconv.Atoi(loadConfigString(db, key, "")); err == nil {
+		return v
+	}
+	return def
+}
+
+func loadConfigBool(db *gorm.DB, key string, def bool) bool {
+	switch strings.ToLower(loadConfigString(db, key, "")) {
+	case "1", "true", "yes", "on":
+		return true
+	case "0", "false", "no", "off":
+		return false
+	}
+	return def
+}
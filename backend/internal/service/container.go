@@ -1,39 +1,59 @@
 package service
 
 import (
+	"log"
+
 	"gorm.io/gorm"
 
 	"red_packet/backend/internal/config"
 )
 
 type Container struct {
-	Auth     *AuthService
-	Referral *ReferralService
-	Reward   *RewardService
-	Risk     *RiskService
-	AdminOps *AdminOpsService
-	Task     *TaskService
-	Lottery  *LotteryService
-	Wallet   *WalletService
-	Withdraw *WithdrawService
-	Config   *ConfigService
+	Auth        *AuthService
+	Referral    *ReferralService
+	Reward      *RewardService
+	Risk        *RiskService
+	AdminOps    *AdminOpsService
+	Task        *TaskService
+	Lottery     *LotteryService
+	Wallet      *WalletService
+	Withdraw    *WithdrawService
+	Payout      *PayoutService
+	KYC         *KYCService
+	Assist      *AssistService
+	Campaign    *CampaignService
+	Currency    *CurrencyService
+	Idempotency *IdempotencyService
+	Config      *ConfigService
 }
 
 func NewContainer(db *gorm.DB, cfg config.Config) *Container {
-	rewardSvc := NewRewardService(db)
 	riskSvc := NewRiskService(db)
-	referralSvc := NewReferralService(db, rewardSvc)
-	lotterySvc := NewLotteryService(db, rewardSvc)
+	rewardSvc := NewRewardService(db, riskSvc)
+	referralSvc := NewReferralService(db, rewardSvc, riskSvc)
+	geoIP, err := LoadGeoIP(cfg.GeoIP.DBPath)
+	if err != nil {
+		log.Printf("geoip disabled: %v", err)
+	}
+	lotterySvc := NewLotteryService(db, rewardSvc, riskSvc, referralSvc, geoIP)
+	payoutSvc := NewPayoutService(db)
+	campaignSvc := NewCampaignService(db)
 	return &Container{
-		Auth:     NewAuthService(db, cfg),
-		Referral: referralSvc,
-		Reward:   rewardSvc,
-		Risk:     riskSvc,
-		AdminOps: NewAdminOpsService(db),
-		Task:     NewTaskService(db, lotterySvc, referralSvc),
-		Lottery:  lotterySvc,
-		Wallet:   NewWalletService(db),
-		Withdraw: NewWithdrawService(db, riskSvc),
-		Config:   NewConfigService(db),
+		Auth:        NewAuthService(db, cfg, riskSvc, referralSvc),
+		Referral:    referralSvc,
+		Reward:      rewardSvc,
+		Risk:        riskSvc,
+		AdminOps:    NewAdminOpsService(db),
+		Task:        NewTaskService(db, lotterySvc, referralSvc, riskSvc, geoIP, cfg.TaskCallback.Secrets),
+		Lottery:     lotterySvc,
+		Wallet:      NewWalletService(db),
+		Withdraw:    NewWithdrawService(db, riskSvc, payoutSvc),
+		Payout:      payoutSvc,
+		KYC:         NewKYCService(db),
+		Assist:      NewAssistService(db, lotterySvc, rewardSvc, riskSvc),
+		Campaign:    campaignSvc,
+		Currency:    NewCurrencyService(db),
+		Idempotency: NewIdempotencyService(db),
+		Config:      NewConfigService(db, campaignSvc),
 	}
 }
@@ -0,0 +1,248 @@
+package service
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"red_packet/backend/internal/models"
+)
+
+type SpendLine struct {
+	Currency    string  `json:"currency"`
+	Rewards     float64 `json:"rewards"`
+	Payouts     float64 `json:"payouts"`
+	Fees        float64 `json:"fees"`
+	Rate        float64 `json:"rate"`
+	RewardsBase float64 `json:"rewards_base"`
+	PayoutsBase float64 `json:"payouts_base"`
+	FeesBase    float64 `json:"fees_base"`
+	MissingRate bool    `json:"missing_rate"`
+}
+
+type SpendReport struct {
+	BaseCurrency string      `json:"base_currency"`
+	From         time.Time   `json:"from"`
+	To           time.Time   `json:"to"`
+	Lines        []SpendLine `json:"lines"`
+	RewardsBase  float64     `json:"rewards_base"`
+	PayoutsBase  float64     `json:"payouts_base"`
+	FeesBase     float64     `json:"fees_base"`
+}
+
+type CurrencyService struct {
+	db *gorm.DB
+}
+
+func NewCurrencyService(db *gorm.DB) *CurrencyService {
+	return &CurrencyService{db: db}
+}
+
+func normalizeCurrency(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+func baseCurrency(db *gorm.DB) string {
+	return normalizeCurrency(loadConfigString(db, "base_currency", "USD"))
+}
+
+func currencyForCountry(db *gorm.DB, country string) string {
+	var m map[string]string
+	if err := jsonUnmarshal(loadConfigString(db, "country_currencies", ""), &m); err == nil {
+		country = strings.ToUpper(strings.TrimSpace(country))
+		for k, v := range m {
+			if strings.EqualFold(k, country) && normalizeCurrency(v) != "" {
+				return normalizeCurrency(v)
+			}
+		}
+	}
+	return baseCurrency(db)
+}
+
+func userCurrency(tx *gorm.DB, userID uint) (string, error) {
+	var user models.User
+	if err := tx.Select("id", "country", "currency").First(&user, userID).Error; err != nil {
+		return "", err
+	}
+	if c := normalizeCurrency(user.Currency); c != "" {
+		return c, nil
+	}
+	return currencyForCountry(tx, user.Country), nil
+}
+
+// fxRate returns the base-currency value of one unit of currency (1 for the
+// base currency itself); ok is false when no rate is configured.
+func fxRate(tx *gorm.DB, currency string) (float64, bool, error) {
+	if currency == baseCurrency(tx) {
+		return 1, true, nil
+	}
+	var rate models.FXRate
+	if err := tx.Where("currency = ?", currency).Limit(1).Find(&rate).Error; err != nil {
+		return 0, false, err
+	}
+	return rate.Rate, rate.Rate > 0, nil
+}
+
+// convertFromBase turns a base-currency config amount into currency. Without a
+// rate the amount is paid in the base currency rather than at face value.
+func convertFromBase(tx *gorm.DB, amount float64, currency string) (float64, string, error) {
+	rate, ok, err := fxRate(tx, currency)
+	if err != nil {
+		return 0, "", err
+	}
+	if !ok {
+		return amount, baseCurrency(tx), nil
+	}
+	if rate == 1 {
+		return amount, currency, nil
+	}
+	return round2(amount / rate), currency, nil
+}
+
+func rewardCurrency(tx *gorm.DB, userID, campaignID uint) (string, error) {
+	if campaignID > 0 {
+		var campaign models.Campaign
+		if err := tx.Select("id", "currency").First(&campaign, campaignID).Error; err != nil {
+			return "", err
+		}
+		if c := normalizeCurrency(campaign.Currency); c != "" {
+			return c, nil
+		}
+	}
+	return userCurrency(tx, userID)
+}
+
+func lockWallet(tx *gorm.DB, userID uint, currency string) (models.Wallet, error) {
+	var wallet models.Wallet
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("user_id = ? AND currency = ?", userID, currency).First(&wallet).Error
+	if err == nil {
+		return wallet, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return wallet, err
+	}
+	wallet = models.Wallet{UserID: userID, Currency: currency}
+	if err := tx.Create(&wallet).Error; err != nil {
+		if !isDuplicate(err) {
+			return wallet, err
+		}
+		err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ? AND currency = ?", userID, currency).First(&wallet).Error
+		return wallet, err
+	}
+	return wallet, nil
+}
+
+// withdrawMinForCurrency prefers withdraw_min_by_currency; otherwise the
+// base-currency fallback is converted at the configured FX rate.
+func withdrawMinForCurrency(db *gorm.DB, currency string, fallback float64) float64 {
+	var m map[string]float64
+	if err := jsonUnmarshal(loadConfigString(db, "withdraw_min_by_currency", ""), &m); err == nil {
+		for k, v := range m {
+			if strings.EqualFold(k, currency) && v > 0 {
+				return v
+			}
+		}
+	}
+	if rate, ok, err := fxRate(db, currency); err == nil && ok && rate != 1 {
+		return round2(fallback / rate)
+	}
+	return fallback
+}
+
+func (s *CurrencyService) ListRates() ([]models.FXRate, error) {
+	var items []models.FXRate
+	if err := s.db.Order("currency ASC").Find(&items).Error; err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func (s *CurrencyService) UpsertRate(currency string, rate float64) (models.FXRate, error) {
+	currency = normalizeCurrency(currency)
+	if currency == "" || len(currency) > 8 || rate <= 0 {
+		return models.FXRate{}, ErrFXRateInvalid
+	}
+	item := models.FXRate{Currency: currency, Rate: rate}
+	if err := s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "currency"}},
+		DoUpdates: clause.AssignmentColumns([]string{"rate", "updated_at"}),
+	}).Create(&item).Error; err != nil {
+		return models.FXRate{}, err
+	}
+	var out models.FXRate
+	err := s.db.Where("currency = ?", currency).First(&out).Error
+	return out, err
+}
+
+func (s *CurrencyService) SpendReport(from, to time.Time) (SpendReport, error) {
+	report := SpendReport{BaseCurrency: baseCurrency(s.db), From: from, To: to, Lines: []SpendLine{}}
+	type sumRow struct {
+		Currency string
+		Total    float64
+	}
+	sum := func(q *gorm.DB, expr string) (map[string]float64, error) {
+		var rows []sumRow
+		if err := q.Select("currency, COALESCE(SUM(" + expr + "),0) AS total").Group("currency").Scan(&rows).Error; err != nil {
+			return nil, err
+		}
+		out := make(map[string]float64, len(rows))
+		for _, r := range rows {
+			out[r.Currency] = r.Total
+		}
+		return out, nil
+	}
+	rewards, err := sum(s.db.Model(&models.Reward{}).Where("created_at >= ? AND created_at < ? AND status <> ?", from, to, "expired"), "amount")
+	if err != nil {
+		return report, err
+	}
+	payouts, err := sum(s.db.Model(&models.WithdrawRequest{}).Where("status = ? AND updated_at >= ? AND updated_at < ?", "paid", from, to), "net_amount")
+	if err != nil {
+		return report, err
+	}
+	fees, err := sum(s.db.Model(&models.PlatformLedger{}).Where("account = ? AND created_at >= ? AND created_at < ?", "withdraw_fee", from, to), "amount")
+	if err != nil {
+		return report, err
+	}
+
+	rates, err := s.ListRates()
+	if err != nil {
+		return report, err
+	}
+	rateBy := map[string]float64{report.BaseCurrency: 1}
+	for _, r := range rates {
+		rateBy[r.Currency] = r.Rate
+	}
+	currencies := map[string]bool{}
+	for _, m := range []map[string]float64{rewards, payouts, fees} {
+		for c := range m {
+			currencies[c] = true
+		}
+	}
+	for c := range currencies {
+		line := SpendLine{Currency: c, Rewards: round2(rewards[c]), Payouts: round2(payouts[c]), Fees: round2(fees[c])}
+		rate, ok := rateBy[c]
+		if !ok {
+			line.MissingRate = true
+		} else {
+			line.Rate = rate
+			line.RewardsBase = round2(rewards[c] * rate)
+			line.PayoutsBase = round2(payouts[c] * rate)
+			line.FeesBase = round2(fees[c] * rate)
+		}
+		report.RewardsBase += line.RewardsBase
+		report.PayoutsBase += line.PayoutsBase
+		report.FeesBase += line.FeesBase
+		report.Lines = append(report.Lines, line)
+	}
+	sort.Slice(report.Lines, func(i, j int) bool { return report.Lines[i].Currency < report.Lines[j].Currency })
+	report.RewardsBase = round2(report.RewardsBase)
+	report.PayoutsBase = round2(report.PayoutsBase)
+	report.FeesBase = round2(report.FeesBase)
+	return report, nil
+}
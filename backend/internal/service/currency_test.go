@@ -0,0 +1,55 @@
+package service
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"red_packet/backend/internal/models"
+)
+
+func TestWithdrawMinConvertedFromBase(t *testing.T) {
+	db := openTestDB(t)
+	code := fmt.Sprintf("Q%06d", time.Now().UnixNano()%1e6)
+	if _, err := NewCurrencyService(db).UpsertRate(code, 0.0001); err != nil {
+		t.Fatalf("upsert rate: %v", err)
+	}
+	if got := withdrawMinForCurrency(db, code, 60); got != 600000 {
+		t.Errorf("withdraw min with rate = %v, want 600000", got)
+	}
+	if got := withdrawMinForCurrency(db, "N"+code[1:], 60); got != 60 {
+		t.Errorf("withdraw min without rate = %v, want 60", got)
+	}
+	if got := withdrawMinForCurrency(db, baseCurrency(db), 60); got != 60 {
+		t.Errorf("withdraw min in base = %v, want 60", got)
+	}
+}
+
+func TestGrantRewardConvertsBaseAmount(t *testing.T) {
+	db := openTestDB(t)
+	code := fmt.Sprintf("R%06d", time.Now().UnixNano()%1e6)
+	if _, err := NewCurrencyService(db).UpsertRate(code, 0.0001); err != nil {
+		t.Fatalf("upsert rate: %v", err)
+	}
+	cases := []struct {
+		currency, want string
+		amount         float64
+	}{
+		{code, code, 25000},
+		{"N" + code[1:], baseCurrency(db), 2.5},
+	}
+	rewards := NewRewardService(db, NewRiskService(db))
+	for _, c := range cases {
+		user := models.User{DeviceHash: "fx-" + c.currency, Currency: c.currency}
+		if err := db.Create(&user).Error; err != nil {
+			t.Fatalf("create user: %v", err)
+		}
+		res, err := rewards.GrantReward(nil, user.ID, 2.5, "lottery_spin", fmt.Sprintf("fx-%d", user.ID), "pending")
+		if err != nil {
+			t.Fatalf("grant: %v", err)
+		}
+		if res.Currency != c.want || res.Frozen != c.amount {
+			t.Errorf("%s: granted %v %s, want %v %s", c.currency, res.Frozen, res.Currency, c.amount, c.want)
+		}
+	}
+}
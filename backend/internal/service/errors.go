@@ -3,16 +3,88 @@ package service
 import "errors"
 
 var (
-	ErrAlreadyBound      = errors.New("already bound")
-	ErrBindSelf          = errors.New("cannot bind self")
-	ErrReferralCode      = errors.New("invalid referral code")
-	ErrAlreadyClaimed    = errors.New("task already claimed")
-	ErrTaskNotFound      = errors.New("task not found")
-	ErrInsufficientFunds = errors.New("insufficient funds")
-	ErrInvalidAmount     = errors.New("invalid amount")
-	ErrWithdrawBelowMin  = errors.New("withdraw amount below minimum")
-	ErrRiskCheckFailed   = errors.New("risk check failed")
-	ErrWithdrawState     = errors.New("invalid withdraw state transition")
-	ErrWithdrawNotFound  = errors.New("withdraw request not found")
-	ErrNoSpinChance      = errors.New("no spin chance")
+	ErrAlreadyBound          = errors.New("already bound")
+	ErrBindSelf              = errors.New("cannot bind self")
+	ErrReferralCode          = errors.New("invalid referral code")
+	ErrAlreadyClaimed        = errors.New("task already claimed")
+	ErrTaskNotFound          = errors.New("task not found")
+	ErrTaskOutOfScope        = errors.New("task is not available in your country")
+	ErrTaskInvalid           = errors.New("invalid task")
+	ErrTaskLocked            = errors.New("task is locked")
+	ErrTaskPrerequisiteCycle = errors.New("task prerequisites form a cycle")
+	ErrTaskUnverified        = errors.New("task completion not verified")
+	ErrTaskTypeUnknown       = errors.New("task type is not registered")
+	ErrTaskCallbackInvalid   = errors.New("invalid task callback")
+	ErrTaskCallbackSignature = errors.New("invalid task callback signature")
+	ErrInsufficientFunds     = errors.New("insufficient funds")
+	ErrInvalidAmount         = errors.New("invalid amount")
+	ErrWithdrawBelowMin      = errors.New("withdraw amount below minimum")
+	ErrRiskCheckFailed       = errors.New("risk check failed")
+	ErrWithdrawState         = errors.New("invalid withdraw state transition")
+	ErrWithdrawNotFound      = errors.New("withdraw request not found")
+	ErrNoSpinChance          = errors.New("no spin chance")
+
+	ErrWithdrawAboveMax         = errors.New("withdraw amount above maximum")
+	ErrWithdrawAmountNotAllowed = errors.New("withdraw amount not in allowed tiers")
+	ErrWithdrawFirstAmount      = errors.New("first withdraw must use the designated amount")
+	ErrWithdrawCooldown         = errors.New("withdraw cooldown not elapsed")
+	ErrWithdrawDailyCount       = errors.New("daily withdraw count limit reached")
+	ErrWithdrawWeeklyCount      = errors.New("weekly withdraw count limit reached")
+	ErrWithdrawDailyAmount      = errors.New("daily withdraw amount limit reached")
+	ErrWithdrawFeeExceeds       = errors.New("withdraw fee exceeds amount")
+
+	ErrPayoutAccountInvalid    = errors.New("invalid payout account")
+	ErrPayoutAccountNotFound   = errors.New("payout account not found")
+	ErrPayoutAccountRequired   = errors.New("payout account required")
+	ErrPayoutAccountTaken      = errors.New("payout account already bound by another user")
+	ErrPayoutAccountLimit      = errors.New("payout account limit reached")
+	ErrPayoutAccountCooldown   = errors.New("payout account is in cooldown")
+	ErrPayoutAccountUnverified = errors.New("payout account not verified")
+
+	ErrKYCRequired       = errors.New("kyc verification required")
+	ErrKYCInvalid        = errors.New("invalid kyc submission")
+	ErrKYCState          = errors.New("invalid kyc state transition")
+	ErrKYCNotFound       = errors.New("kyc submission not found")
+	ErrKYCDocUnsupported = errors.New("kyc document type not supported for country")
+
+	ErrRiskFlagNotFound = errors.New("risk flag not found")
+	ErrRiskFlagState    = errors.New("invalid risk flag state transition")
+
+	ErrRegisterLimited = errors.New("too many registrations from this device or network")
+	ErrAccountRequired = errors.New("phone or email is required")
+	ErrAccountTaken    = errors.New("phone or email already registered to another account")
+
+	ErrUserNotFound          = errors.New("user not found")
+	ErrReferralCodeChecksum  = errors.New("referral code checksum mismatch, please check for typos")
+	ErrReferralCodeFormat    = errors.New("vanity code must be 4-16 letters or digits")
+	ErrReferralCodeTaken     = errors.New("referral code already in use")
+	ErrReferralCodeCooldown  = errors.New("referral code was rotated recently")
+	ErrReferralCodeExhausted = errors.New("could not allocate a unique referral code")
+
+	ErrBindWindowClosed = errors.New("referral binding window has closed")
+	ErrBindAfterTask    = errors.New("referral must be bound before the first task claim")
+	ErrBindInviterNewer = errors.New("inviter registered after invitee")
+	ErrBindSharedDevice = errors.New("inviter and invitee share a device")
+	ErrBindSharedIP     = errors.New("inviter and invitee share an ip address")
+	ErrBindCycle        = errors.New("binding would create a referral cycle")
+
+	ErrAssistDisabled      = errors.New("assist campaign is disabled")
+	ErrAssistNotFound      = errors.New("assist session not found")
+	ErrAssistClosed        = errors.New("assist session is no longer open")
+	ErrAssistSelf          = errors.New("cannot assist your own session")
+	ErrAssistAlreadyHelped = errors.New("already assisted this session")
+	ErrAssistHelperLimit   = errors.New("daily assist limit reached")
+	ErrAssistSessionLimit  = errors.New("daily assist session limit reached")
+	ErrAssistSharedEnv     = errors.New("helper shares device or network with session owner")
+	ErrAssistRestricted    = errors.New("account is not eligible to assist")
+
+	ErrCampaignNotFound   = errors.New("campaign not found")
+	ErrCampaignInactive   = errors.New("campaign is not active")
+	ErrCampaignInvalid    = errors.New("invalid campaign")
+	ErrCampaignOutOfScope = errors.New("campaign is not available in your country")
+
+	ErrFXRateInvalid = errors.New("invalid fx rate")
+
+	ErrIdempotencyInProgress = errors.New("request with this idempotency key is still processing")
+	ErrIdempotencyMismatch   = errors.New("idempotency key reused with a different request")
 )
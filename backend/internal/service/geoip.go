@@ -0,0 +1,87 @@
+package service
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"net/netip"
+	"os"
+	"sort"
+	"strings"
+)
+
+type geoRange struct {
+	start   netip.Addr
+	end     netip.Addr
+	country string
+}
+
+// GeoIP resolves countries from a local CSV file with rows of
+// "start_ip,end_ip,country"; lines starting with # are ignored.
+type GeoIP struct {
+	ranges []geoRange
+}
+
+func LoadGeoIP(path string) (*GeoIP, error) {
+	if strings.TrimSpace(path) == "" {
+		return nil, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.Comment = '#'
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	g := &GeoIP{}
+	for {
+		row, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(row) < 3 {
+			continue
+		}
+		start, err := netip.ParseAddr(strings.TrimSpace(row[0]))
+		if err != nil {
+			continue
+		}
+		end, err := netip.ParseAddr(strings.TrimSpace(row[1]))
+		if err != nil || start.BitLen() != end.BitLen() || end.Less(start) {
+			continue
+		}
+		country := strings.ToUpper(strings.TrimSpace(row[2]))
+		if country == "" {
+			continue
+		}
+		g.ranges = append(g.ranges, geoRange{start: start.Unmap(), end: end.Unmap(), country: country})
+	}
+	sort.Slice(g.ranges, func(i, j int) bool { return g.ranges[i].start.Less(g.ranges[j].start) })
+	return g, nil
+}
+
+func (g *GeoIP) Lookup(ip string) string {
+	if g == nil || len(g.ranges) == 0 {
+		return ""
+	}
+	addr, err := netip.ParseAddr(strings.TrimSpace(ip))
+	if err != nil {
+		return ""
+	}
+	addr = addr.Unmap()
+	i := sort.Search(len(g.ranges), func(i int) bool { return addr.Less(g.ranges[i].start) }) - 1
+	if i < 0 {
+		return ""
+	}
+	r := g.ranges[i]
+	if r.start.BitLen() != addr.BitLen() || r.end.Less(addr) {
+		return ""
+	}
+	return r.country
+}
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"red_packet/backend/internal/models"
+)
+
+type IdempotencyService struct {
+	db  *gorm.DB
+	ttl time.Duration
+}
+
+func NewIdempotencyService(db *gorm.DB) *IdempotencyService {
+	return &IdempotencyService{db: db, ttl: 24 * time.Hour}
+}
+
+func (s *IdempotencyService) Begin(userID uint, key, route, requestHash string) (models.IdempotencyRecord, bool, error) {
+	now := time.Now()
+	rec := models.IdempotencyRecord{
+		UserID:      userID,
+		Key:         key,
+		Route:       route,
+		RequestHash: requestHash,
+		Status:      "processing",
+		ExpireAt:    now.Add(s.ttl),
+	}
+	if err := s.db.Create(&rec).Error; err == nil {
+		return rec, false, nil
+	} else if !isDuplicate(err) {
+		return rec, false, err
+	}
+
+	var existed models.IdempotencyRecord
+	if err := s.db.Where("user_id = ? AND `key` = ?", userID, key).First(&existed).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return rec, false, ErrIdempotencyInProgress
+		}
+		return rec, false, err
+	}
+	if existed.ExpireAt.Before(now) {
+		res := s.db.Where("id = ? AND expire_at < ?", existed.ID, now).Delete(&models.IdempotencyRecord{})
+		if res.Error != nil {
+			return rec, false, res.Error
+		}
+		return s.Begin(userID, key, route, requestHash)
+	}
+	if existed.Route != route || existed.RequestHash != requestHash {
+		return existed, false, ErrIdempotencyMismatch
+	}
+	if existed.Status != "completed" {
+		return existed, false, ErrIdempotencyInProgress
+	}
+	return existed, true, nil
+}
+
+func (s *IdempotencyService) Complete(id uint, status int, body string) error {
+	return s.db.Model(&models.IdempotencyRecord{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"status":          "completed",
+		"response_status": status,
+		"response_body":   body,
+	}).Error
+}
+
+func (s *IdempotencyService) Release(id uint) error {
+	return s.db.Delete(&models.IdempotencyRecord{}, id).Error
+}
+
+func (s *IdempotencyService) PurgeExpired(now time.Time) (int, error) {
+	res := s.db.Where("expire_at < ?", now).Limit(1000).Delete(&models.IdempotencyRecord{})
+	return int(res.RowsAffected), res.Error
+}
@@ -0,0 +1,213 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"red_packet/backend/internal/models"
+)
+
+type KYCInput struct {
+	DocType   string `json:"doc_type"`
+	DocNumber string `json:"doc_number"`
+	RealName  string `json:"real_name"`
+}
+
+type KYCRequirement struct {
+	Required bool     `json:"required"`
+	DocTypes []string `json:"doc_types"`
+}
+
+type KYCSubmissionView struct {
+	ID         uint       `json:"id"`
+	UserID     uint       `json:"user_id"`
+	Country    string     `json:"country"`
+	DocType    string     `json:"doc_type"`
+	DocNumber  string     `json:"doc_number"`
+	RealName   string     `json:"real_name"`
+	Status     string     `json:"status"`
+	ReviewNote string     `json:"review_note"`
+	ReviewedAt *time.Time `json:"reviewed_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+type KYCStatusView struct {
+	Status    string             `json:"status"`
+	Required  bool               `json:"required"`
+	DocTypes  []string           `json:"doc_types"`
+	Latest    *KYCSubmissionView `json:"latest,omitempty"`
+	CanSubmit bool               `json:"can_submit"`
+	Country   string             `json:"country"`
+}
+
+type KYCService struct {
+	db *gorm.DB
+}
+
+func NewKYCService(db *gorm.DB) *KYCService {
+	return &KYCService{db: db}
+}
+
+func (s *KYCService) Status(userID uint) (KYCStatusView, error) {
+	var user models.User
+	if err := s.db.First(&user, userID).Error; err != nil {
+		return KYCStatusView{}, err
+	}
+	req := loadKYCRequirement(s.db, user.Country)
+	out := KYCStatusView{
+		Status:    kycStatusOf(user),
+		Required:  req.Required,
+		DocTypes:  req.DocTypes,
+		Country:   user.Country,
+		CanSubmit: validKYCTransition(kycStatusOf(user), "submitted"),
+	}
+	var latest models.KYCSubmission
+	if err := s.db.Where("user_id = ?", userID).Order("id DESC").First(&latest).Error; err == nil {
+		view := toKYCSubmissionView(latest)
+		out.Latest = &view
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return KYCStatusView{}, err
+	}
+	return out, nil
+}
+
+func (s *KYCService) Submit(userID uint, in KYCInput) (KYCSubmissionView, error) {
+	in.DocType = strings.ToLower(strings.TrimSpace(in.DocType))
+	in.DocNumber = strings.TrimSpace(in.DocNumber)
+	in.RealName = strings.TrimSpace(in.RealName)
+	if in.DocType == "" || in.DocNumber == "" || in.RealName == "" {
+		return KYCSubmissionView{}, ErrKYCInvalid
+	}
+
+	var item models.KYCSubmission
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.First(&user, userID).Error; err != nil {
+			return err
+		}
+		if !validKYCTransition(kycStatusOf(user), "submitted") {
+			return ErrKYCState
+		}
+		req := loadKYCRequirement(tx, user.Country)
+		if len(req.DocTypes) > 0 && !containsString(req.DocTypes, in.DocType) {
+			return ErrKYCDocUnsupported
+		}
+
+		item = models.KYCSubmission{
+			UserID:    userID,
+			Country:   user.Country,
+			DocType:   in.DocType,
+			DocNumber: in.DocNumber,
+			RealName:  in.RealName,
+			Status:    "submitted",
+		}
+		if err := tx.Create(&item).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.User{}).Where("id = ?", userID).Update("kyc_status", "submitted").Error
+	})
+	if err != nil {
+		return KYCSubmissionView{}, err
+	}
+	return toKYCSubmissionView(item), nil
+}
+
+func (s *KYCService) ListSubmissions(status string, page, size int) ([]models.KYCSubmission, error) {
+	if page < 1 {
+		page = 1
+	}
+	if size < 1 || size > 100 {
+		size = 20
+	}
+	q := s.db.Model(&models.KYCSubmission{})
+	if status != "" {
+		q = q.Where("status = ?", status)
+	}
+	var items []models.KYCSubmission
+	if err := q.Order("id DESC").Offset((page - 1) * size).Limit(size).Find(&items).Error; err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func (s *KYCService) Review(submissionID uint, status, note string) (models.KYCSubmission, error) {
+	var item models.KYCSubmission
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&item, submissionID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrKYCNotFound
+			}
+			return err
+		}
+		if !validKYCTransition(item.Status, status) {
+			return ErrKYCState
+		}
+		now := time.Now()
+		item.Status = status
+		item.ReviewNote = note
+		item.ReviewedAt = &now
+		if err := tx.Save(&item).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.User{}).Where("id = ?", item.UserID).Update("kyc_status", status).Error
+	})
+	if err != nil {
+		return models.KYCSubmission{}, err
+	}
+	return item, nil
+}
+
+func validKYCTransition(from string, to string) bool {
+	if (from == "" || from == "none" || from == "rejected") && to == "submitted" {
+		return true
+	}
+	if from == "submitted" && (to == "verified" || to == "rejected") {
+		return true
+	}
+	return false
+}
+
+func kycStatusOf(user models.User) string {
+	if user.KYCStatus == "" {
+		return "none"
+	}
+	return user.KYCStatus
+}
+
+func loadKYCRequirement(db *gorm.DB, country string) KYCRequirement {
+	var rules map[string]KYCRequirement
+	if err := jsonUnmarshal(loadConfigString(db, "kyc_requirements", ""), &rules); err != nil {
+		return KYCRequirement{}
+	}
+	if req, ok := rules[strings.ToUpper(country)]; ok && country != "" {
+		return req
+	}
+	return rules["*"]
+}
+
+func toKYCSubmissionView(item models.KYCSubmission) KYCSubmissionView {
+	return KYCSubmissionView{
+		ID:         item.ID,
+		UserID:     item.UserID,
+		Country:    item.Country,
+		DocType:    item.DocType,
+		DocNumber:  maskAccountNo(item.DocNumber),
+		RealName:   maskName(item.RealName),
+		Status:     item.Status,
+		ReviewNote: item.ReviewNote,
+		ReviewedAt: item.ReviewedAt,
+		CreatedAt:  item.CreatedAt,
+	}
+}
+
+func containsString(items []string, target string) bool {
+	for _, item := range items {
+		if item == target {
+			return true
+		}
+	}
+	return false
+}
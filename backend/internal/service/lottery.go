@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log"
 	"math"
 	"math/rand"
 	"strconv"
@@ -24,6 +25,7 @@ type SpinResult struct {
 
 type LotteryStatus struct {
 	SpinCount  int     `json:"spin_count"`
+	Currency   string  `json:"currency"`
 	Target     float64 `json:"target"`
 	Balance    float64 `json:"balance"`
 	Pending    float64 `json:"pending"`
@@ -32,21 +34,55 @@ type LotteryStatus struct {
 }
 
 type LotteryService struct {
-	db        *gorm.DB
-	rewardSvc *RewardService
+	db          *gorm.DB
+	rewardSvc   *RewardService
+	riskSvc     *RiskService
+	referralSvc *ReferralService
+	geoIP       *GeoIP
 }
 
-func NewLotteryService(db *gorm.DB, rewardSvc *RewardService) *LotteryService {
-	return &LotteryService{db: db, rewardSvc: rewardSvc}
+func NewLotteryService(db *gorm.DB, rewardSvc *RewardService, riskSvc *RiskService, referralSvc *ReferralService, geoIP *GeoIP) *LotteryService {
+	return &LotteryService{db: db, rewardSvc: rewardSvc, riskSvc: riskSvc, referralSvc: referralSvc, geoIP: geoIP}
 }
 
-func (s *LotteryService) GetStatus(userID uint) (LotteryStatus, error) {
-	spinCount, err := s.GetSpinCount(userID)
+func (s *LotteryService) GetStatus(userID, campaignID uint, meta RequestMeta) (LotteryStatus, error) {
+	spinCount, err := s.GetSpinCount(userID, campaignID)
 	if err != nil {
 		return LotteryStatus{}, err
 	}
-	target := s.loadWithdrawMin()
-	balance, err := s.getBalance(userID)
+	if campaignID > 0 {
+		_, country, err := userCountry(s.db, s.geoIP, userID, meta)
+		if err != nil {
+			return LotteryStatus{}, err
+		}
+		campaign, err := loadActiveCampaign(s.db, campaignID, country, time.Now())
+		if err != nil && !errors.Is(err, ErrCampaignInactive) {
+			return LotteryStatus{}, err
+		}
+		balance, pending, err := s.campaignBalanceTx(s.db, userID, campaignID)
+		if err != nil {
+			return LotteryStatus{}, err
+		}
+		currency, err := rewardCurrency(s.db, userID, campaignID)
+		if err != nil {
+			return LotteryStatus{}, err
+		}
+		return LotteryStatus{
+			SpinCount:  spinCount,
+			Currency:   currency,
+			Target:     campaign.Target,
+			Balance:    balance,
+			Pending:    pending,
+			Needed:     math.Max(campaign.Target-balance, 0),
+			Unlockable: pending,
+		}, nil
+	}
+	currency, _, err := wheelCurrency(s.db, userID)
+	if err != nil {
+		return LotteryStatus{}, err
+	}
+	target := withdrawMinForCurrency(s.db, currency, s.loadWithdrawMin())
+	balance, err := s.getBalanceTx(s.db, userID, currency)
 	if err != nil {
 		return LotteryStatus{}, err
 	}
@@ -60,6 +96,7 @@ func (s *LotteryService) GetStatus(userID uint) (LotteryStatus, error) {
 	}
 	return LotteryStatus{
 		SpinCount:  spinCount,
+		Currency:   currency,
 		Target:     target,
 		Balance:    balance,
 		Pending:    summary.Pending,
@@ -68,11 +105,26 @@ func (s *LotteryService) GetStatus(userID uint) (LotteryStatus, error) {
 	}, nil
 }
 
-func (s *LotteryService) Spin(userID uint) (SpinResult, error) {
+func (s *LotteryService) Spin(userID, campaignID uint, meta RequestMeta) (SpinResult, error) {
 	var result SpinResult
-	target := s.loadWithdrawMin()
-	return result, s.db.Transaction(func(tx *gorm.DB) error {
-		chance, err := s.getOrCreateChance(tx, userID)
+	if _, err := s.riskSvc.Check(userID, RiskActionSpin, meta); err != nil {
+		return result, err
+	}
+	currency, rate, err := wheelCurrency(s.db, userID)
+	if err != nil {
+		return result, err
+	}
+	target := withdrawMinForCurrency(s.db, currency, s.loadWithdrawMin())
+	_, country, err := userCountry(s.db, s.geoIP, userID, meta)
+	if err != nil {
+		return result, err
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		campaign, err := loadActiveCampaign(tx, campaignID, country, time.Now())
+		if err != nil {
+			return err
+		}
+		chance, err := s.getOrCreateChance(tx, userID, campaignID)
 		if err != nil {
 			return err
 		}
@@ -80,15 +132,31 @@ func (s *LotteryService) Spin(userID uint) (SpinResult, error) {
 			return ErrNoSpinChance
 		}
 
-		balance, err := s.getBalanceTx(tx, userID)
-		if err != nil {
-			return err
+		var amount, prize float64
+		var prizeType string
+		var segmentIndex int
+		if campaignID > 0 {
+			amount, prizeType, segmentIndex, err = s.drawForCampaign(tx, userID, campaign)
+			if err != nil {
+				return err
+			}
+		} else {
+			balance, err := s.getBalanceTx(tx, userID, currency)
+			if err != nil {
+				return err
+			}
+			// Global prizes are base-currency values; draw on base-currency
+			// progress and credit the converted amount.
+			prize, prizeType = drawPrize(balance*rate, target*rate)
+			segmentIndex = pickSegmentIndex(prizeType)
+			if amount, _, err = convertFromBase(tx, prize, currency); err != nil {
+				return err
+			}
 		}
-		amount, prizeType := drawPrize(balance, target)
-		segmentIndex := pickSegmentIndex(prizeType)
 
 		record := models.SpinRecord{
 			UserID:       userID,
+			CampaignID:   campaignID,
 			Amount:       amount,
 			PrizeType:    prizeType,
 			SegmentIndex: segmentIndex,
@@ -102,7 +170,13 @@ func (s *LotteryService) Spin(userID uint) (SpinResult, error) {
 		}
 
 		if amount > 0 {
-			if _, err := s.rewardSvc.GrantReward(tx, userID, amount, "lottery_spin", fmt.Sprintf("%d", record.ID), "pending"); err != nil {
+			refID := fmt.Sprintf("%d", record.ID)
+			if campaignID > 0 {
+				_, err = s.rewardSvc.GrantCampaignReward(tx, userID, campaignID, amount, "lottery_spin", refID, "pending")
+			} else {
+				_, err = s.rewardSvc.GrantReward(tx, userID, prize, "lottery_spin", refID, "pending")
+			}
+			if err != nil {
 				return err
 			}
 		}
@@ -124,6 +198,45 @@ func (s *LotteryService) Spin(userID uint) (SpinResult, error) {
 		}
 		return nil
 	})
+	if err != nil {
+		return result, err
+	}
+	s.riskSvc.DetectSpin(userID)
+	if _, err := s.referralSvc.CheckInviteValidity(userID); err != nil {
+		log.Printf("invite validity for user %d: %v", userID, err)
+	}
+	return result, nil
+}
+
+func (s *LotteryService) drawForCampaign(tx *gorm.DB, userID uint, campaign models.Campaign) (float64, string, int, error) {
+	var amount float64
+	var prizeType string
+	var segmentIndex int
+	if prizes, err := parseCampaignWheel(campaign.WheelConfig); err == nil {
+		amount, prizeType, segmentIndex = drawCampaignPrize(prizes)
+	} else {
+		balance, _, err := s.campaignBalanceTx(tx, userID, campaign.ID)
+		if err != nil {
+			return 0, "", 0, err
+		}
+		target := campaign.Target
+		if target <= 0 {
+			target = s.loadWithdrawMin()
+		}
+		amount, prizeType = drawPrize(balance, target)
+		segmentIndex = pickSegmentIndex(prizeType)
+	}
+	if amount <= 0 {
+		return 0, prizeType, segmentIndex, nil
+	}
+	ok, err := reserveCampaignBudget(tx, campaign, amount)
+	if err != nil {
+		return 0, "", 0, err
+	}
+	if !ok {
+		return 0, "thanks", segmentIndex, nil
+	}
+	return amount, prizeType, segmentIndex, nil
 }
 
 func (s *LotteryService) AddChances(userID uint, count int) (int, error) {
@@ -134,11 +247,15 @@ func (s *LotteryService) AddChances(userID uint, count int) (int, error) {
 }
 
 func (s *LotteryService) AddChancesTx(tx *gorm.DB, userID uint, count int) (int, error) {
+	return s.AddCampaignChancesTx(tx, userID, 0, count)
+}
+
+func (s *LotteryService) AddCampaignChancesTx(tx *gorm.DB, userID, campaignID uint, count int) (int, error) {
 	if count <= 0 {
 		return 0, nil
 	}
 	var out int
-	chance, err := s.getOrCreateChance(tx, userID)
+	chance, err := s.getOrCreateChance(tx, userID, campaignID)
 	if err != nil {
 		return 0, err
 	}
@@ -150,15 +267,15 @@ func (s *LotteryService) AddChancesTx(tx *gorm.DB, userID uint, count int) (int,
 	return out, nil
 }
 
-func (s *LotteryService) GetSpinCount(userID uint) (int, error) {
-	chance, err := s.getOrCreateChance(s.db, userID)
+func (s *LotteryService) GetSpinCount(userID, campaignID uint) (int, error) {
+	chance, err := s.getOrCreateChance(s.db, userID, campaignID)
 	if err != nil {
 		return 0, err
 	}
 	return chance.Count, nil
 }
 
-func (s *LotteryService) ListRecords(userID uint, page, size int) ([]models.SpinRecord, error) {
+func (s *LotteryService) ListRecords(userID, campaignID uint, page, size int) ([]models.SpinRecord, error) {
 	if page < 1 {
 		page = 1
 	}
@@ -166,7 +283,7 @@ func (s *LotteryService) ListRecords(userID uint, page, size int) ([]models.Spin
 		size = 20
 	}
 	var records []models.SpinRecord
-	if err := s.db.Where("user_id = ?", userID).
+	if err := s.db.Where("user_id = ? AND campaign_id = ?", userID, campaignID).
 		Order("id DESC").
 		Offset((page - 1) * size).
 		Limit(size).
@@ -176,11 +293,11 @@ func (s *LotteryService) ListRecords(userID uint, page, size int) ([]models.Spin
 	return records, nil
 }
 
-func (s *LotteryService) getOrCreateChance(tx *gorm.DB, userID uint) (models.SpinChance, error) {
+func (s *LotteryService) getOrCreateChance(tx *gorm.DB, userID, campaignID uint) (models.SpinChance, error) {
 	var chance models.SpinChance
-	if err := tx.Where("user_id = ?", userID).First(&chance).Error; err != nil {
+	if err := tx.Where("user_id = ? AND campaign_id = ?", userID, campaignID).First(&chance).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			chance = models.SpinChance{UserID: userID, Count: 0}
+			chance = models.SpinChance{UserID: userID, CampaignID: campaignID, Count: 0}
 			if err := tx.Create(&chance).Error; err != nil {
 				return models.SpinChance{}, err
 			}
@@ -191,13 +308,50 @@ func (s *LotteryService) getOrCreateChance(tx *gorm.DB, userID uint) (models.Spi
 	return chance, nil
 }
 
-func (s *LotteryService) getBalance(userID uint) (float64, error) {
-	return s.getBalanceTx(s.db, userID)
+func (s *LotteryService) campaignBalanceTx(tx *gorm.DB, userID, campaignID uint) (float64, float64, error) {
+	type row struct {
+		Status string
+		Total  float64
+	}
+	var rows []row
+	if err := tx.Model(&models.Reward{}).
+		Select("status, COALESCE(SUM(amount),0) AS total").
+		Where("user_id = ? AND campaign_id = ? AND status IN ?", userID, campaignID, []string{"pending", "unlocked"}).
+		Group("status").
+		Scan(&rows).Error; err != nil {
+		return 0, 0, err
+	}
+	var unlocked, pending float64
+	for _, r := range rows {
+		if r.Status == "unlocked" {
+			unlocked = r.Total
+		} else {
+			pending = r.Total
+		}
+	}
+	return unlocked, pending, nil
+}
+
+// wheelCurrency is the wallet the global wheel pays into and the base-currency
+// value of one unit of it; without an FX rate prizes stay in the base currency.
+func wheelCurrency(tx *gorm.DB, userID uint) (string, float64, error) {
+	currency, err := userCurrency(tx, userID)
+	if err != nil {
+		return "", 0, err
+	}
+	rate, ok, err := fxRate(tx, currency)
+	if err != nil {
+		return "", 0, err
+	}
+	if !ok {
+		return baseCurrency(tx), 1, nil
+	}
+	return currency, rate, nil
 }
 
-func (s *LotteryService) getBalanceTx(tx *gorm.DB, userID uint) (float64, error) {
+func (s *LotteryService) getBalanceTx(tx *gorm.DB, userID uint, currency string) (float64, error) {
 	var wallet models.Wallet
-	if err := tx.Where("user_id = ?", userID).First(&wallet).Error; err != nil {
+	if err := tx.Where("user_id = ? AND currency = ?", userID, currency).First(&wallet).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return 0, nil
 		}
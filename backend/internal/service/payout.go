@@ -0,0 +1,295 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"red_packet/backend/internal/models"
+)
+
+type PayoutAccountInput struct {
+	Method      string `json:"method"`
+	Provider    string `json:"provider"`
+	AccountNo   string `json:"account_no"`
+	AccountName string `json:"account_name"`
+	Country     string `json:"country"`
+}
+
+type PayoutAccountView struct {
+	ID            uint       `json:"id"`
+	Method        string     `json:"method"`
+	Provider      string     `json:"provider"`
+	AccountNo     string     `json:"account_no"`
+	AccountName   string     `json:"account_name"`
+	Country       string     `json:"country"`
+	IsDefault     bool       `json:"is_default"`
+	Verified      bool       `json:"verified"`
+	CooldownUntil time.Time  `json:"cooldown_until"`
+	CreatedAt     time.Time  `json:"created_at"`
+	VerifiedAt    *time.Time `json:"verified_at,omitempty"`
+}
+
+type PayoutService struct {
+	db *gorm.DB
+}
+
+var payoutMethods = map[string]bool{
+	"ewallet":      true,
+	"bank":         true,
+	"mobile_money": true,
+}
+
+func NewPayoutService(db *gorm.DB) *PayoutService {
+	return &PayoutService{db: db}
+}
+
+func (s *PayoutService) List(userID uint) ([]PayoutAccountView, error) {
+	var items []models.PayoutAccount
+	if err := s.db.Where("user_id = ? AND status = ?", userID, "active").
+		Order("is_default DESC, id ASC").
+		Find(&items).Error; err != nil {
+		return nil, err
+	}
+	out := make([]PayoutAccountView, 0, len(items))
+	for _, item := range items {
+		out = append(out, toPayoutAccountView(item))
+	}
+	return out, nil
+}
+
+func (s *PayoutService) Bind(userID uint, in PayoutAccountInput) (PayoutAccountView, error) {
+	in.Method = strings.ToLower(strings.TrimSpace(in.Method))
+	in.Provider = strings.ToLower(strings.TrimSpace(in.Provider))
+	in.AccountNo = normalizeAccountNo(in.AccountNo)
+	in.AccountName = strings.TrimSpace(in.AccountName)
+	in.Country = strings.ToUpper(strings.TrimSpace(in.Country))
+	if !payoutMethods[in.Method] || in.AccountNo == "" || in.AccountName == "" {
+		return PayoutAccountView{}, ErrPayoutAccountInvalid
+	}
+
+	cooldown := time.Duration(loadConfigFloat(s.db, "payout_account_cooldown_hours", 24) * float64(time.Hour))
+	maxAccounts := loadConfigInt(s.db, "payout_account_max", 3)
+
+	var account models.PayoutAccount
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var activeCount int64
+		if err := tx.Model(&models.PayoutAccount{}).
+			Where("user_id = ? AND status = ?", userID, "active").
+			Count(&activeCount).Error; err != nil {
+			return err
+		}
+
+		// Only another user's active account blocks the number; removed ones
+		// are released for anyone to bind.
+		var holders []models.PayoutAccount
+		if err := tx.Where("method = ? AND provider = ? AND account_no = ? AND (status = ? OR user_id = ?)",
+			in.Method, in.Provider, in.AccountNo, "active", userID).
+			Order("id DESC").Find(&holders).Error; err != nil {
+			return err
+		}
+		for _, h := range holders {
+			if h.Status == "active" && h.UserID != userID {
+				return ErrPayoutAccountTaken
+			}
+		}
+		for _, h := range holders {
+			if h.UserID == userID {
+				account = h
+				break
+			}
+		}
+		if account.ID > 0 && account.Status == "active" {
+			return nil
+		}
+		if maxAccounts > 0 && activeCount >= int64(maxAccounts) {
+			return ErrPayoutAccountLimit
+		}
+
+		account.UserID = userID
+		account.Method = in.Method
+		account.Provider = in.Provider
+		account.AccountNo = in.AccountNo
+		account.AccountName = in.AccountName
+		account.Country = in.Country
+		account.Status = "active"
+		activeKey := payoutActiveKey(in.Method, in.Provider, in.AccountNo)
+		account.ActiveKey = &activeKey
+		account.IsDefault = activeCount == 0
+		account.VerifiedAt = nil
+		account.CooldownUntil = time.Now().Add(cooldown)
+		if err := tx.Save(&account).Error; err != nil {
+			if isDuplicate(err) {
+				return ErrPayoutAccountTaken
+			}
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return PayoutAccountView{}, err
+	}
+	return toPayoutAccountView(account), nil
+}
+
+func payoutActiveKey(method, provider, accountNo string) string {
+	return method + "|" + provider + "|" + accountNo
+}
+
+func (s *PayoutService) Remove(userID, accountID uint) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var account models.PayoutAccount
+		if err := tx.Where("id = ? AND user_id = ? AND status = ?", accountID, userID, "active").First(&account).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrPayoutAccountNotFound
+			}
+			return err
+		}
+		wasDefault := account.IsDefault
+		account.Status = "removed"
+		account.ActiveKey = nil
+		account.IsDefault = false
+		if err := tx.Save(&account).Error; err != nil {
+			return err
+		}
+		if !wasDefault {
+			return nil
+		}
+		var next models.PayoutAccount
+		if err := tx.Where("user_id = ? AND status = ?", userID, "active").Order("id ASC").First(&next).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil
+			}
+			return err
+		}
+		return tx.Model(&next).Update("is_default", true).Error
+	})
+}
+
+func (s *PayoutService) SetDefault(userID, accountID uint) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var account models.PayoutAccount
+		if err := tx.Where("id = ? AND user_id = ? AND status = ?", accountID, userID, "active").First(&account).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrPayoutAccountNotFound
+			}
+			return err
+		}
+		if err := tx.Model(&models.PayoutAccount{}).
+			Where("user_id = ? AND id <> ?", userID, accountID).
+			Update("is_default", false).Error; err != nil {
+			return err
+		}
+		return tx.Model(&account).Update("is_default", true).Error
+	})
+}
+
+func (s *PayoutService) ListAll(userID uint, page, size int) ([]PayoutAccountView, error) {
+	if page < 1 {
+		page = 1
+	}
+	if size < 1 || size > 100 {
+		size = 20
+	}
+	q := s.db.Model(&models.PayoutAccount{})
+	if userID > 0 {
+		q = q.Where("user_id = ?", userID)
+	}
+	var items []models.PayoutAccount
+	if err := q.Order("id DESC").Offset((page - 1) * size).Limit(size).Find(&items).Error; err != nil {
+		return nil, err
+	}
+	out := make([]PayoutAccountView, 0, len(items))
+	for _, item := range items {
+		out = append(out, toPayoutAccountView(item))
+	}
+	return out, nil
+}
+
+func (s *PayoutService) Verify(accountID uint) (PayoutAccountView, error) {
+	var account models.PayoutAccount
+	if err := s.db.Where("id = ? AND status = ?", accountID, "active").First(&account).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return PayoutAccountView{}, ErrPayoutAccountNotFound
+		}
+		return PayoutAccountView{}, err
+	}
+	now := time.Now()
+	account.VerifiedAt = &now
+	if err := s.db.Save(&account).Error; err != nil {
+		return PayoutAccountView{}, err
+	}
+	return toPayoutAccountView(account), nil
+}
+
+func (s *PayoutService) ResolveForWithdraw(tx *gorm.DB, userID, accountID uint) (models.PayoutAccount, error) {
+	account, err := s.FindActive(tx, userID, accountID)
+	if err != nil {
+		return account, err
+	}
+	if time.Now().Before(account.CooldownUntil) {
+		return account, ErrPayoutAccountCooldown
+	}
+	if account.VerifiedAt == nil && loadConfigBool(tx, "payout_account_require_verify", false) {
+		return account, ErrPayoutAccountUnverified
+	}
+	return account, nil
+}
+
+func (s *PayoutService) FindActive(tx *gorm.DB, userID, accountID uint) (models.PayoutAccount, error) {
+	var account models.PayoutAccount
+	q := tx.Where("user_id = ? AND status = ?", userID, "active")
+	if accountID > 0 {
+		q = q.Where("id = ?", accountID)
+	} else {
+		q = q.Where("is_default = ?", true)
+	}
+	if err := q.First(&account).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			if accountID > 0 {
+				return account, ErrPayoutAccountNotFound
+			}
+			return account, ErrPayoutAccountRequired
+		}
+		return account, err
+	}
+	return account, nil
+}
+
+func toPayoutAccountView(item models.PayoutAccount) PayoutAccountView {
+	return PayoutAccountView{
+		ID:            item.ID,
+		Method:        item.Method,
+		Provider:      item.Provider,
+		AccountNo:     maskAccountNo(item.AccountNo),
+		AccountName:   maskName(item.AccountName),
+		Country:       item.Country,
+		IsDefault:     item.IsDefault,
+		Verified:      item.VerifiedAt != nil,
+		CooldownUntil: item.CooldownUntil,
+		CreatedAt:     item.CreatedAt,
+		VerifiedAt:    item.VerifiedAt,
+	}
+}
+
+func normalizeAccountNo(raw string) string {
+	return strings.NewReplacer(" ", "", "-", "").Replace(strings.TrimSpace(raw))
+}
+
+func maskAccountNo(raw string) string {
+	runes := []rune(raw)
+	if len(runes) <= 4 {
+		return strings.Repeat("*", len(runes))
+	}
+	return strings.Repeat("*", len(runes)-4) + string(runes[len(runes)-4:])
+}
+
+func maskName(raw string) string {
+	runes := []rune(raw)
+	if len(runes) <= 1 {
+		return raw
+	}
+	return string(runes[0]) + strings.Repeat("*", len(runes)-1)
+}
@@ -0,0 +1,43 @@
+package service
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"red_packet/backend/internal/models"
+)
+
+func TestBindReleasesRemovedAccountNumber(t *testing.T) {
+	db := openTestDB(t)
+	svc := NewPayoutService(db)
+	in := PayoutAccountInput{Method: "ewallet", Provider: "dana", AccountNo: fmt.Sprintf("08%d", time.Now().UnixNano()%1e10), AccountName: "Tester"}
+	users := make([]models.User, 2)
+	for i := range users {
+		users[i] = models.User{DeviceHash: fmt.Sprintf("payout-%d-%d", i, time.Now().UnixNano())}
+		if err := db.Create(&users[i]).Error; err != nil {
+			t.Fatalf("create user: %v", err)
+		}
+	}
+
+	first, err := svc.Bind(users[0].ID, in)
+	if err != nil {
+		t.Fatalf("first bind: %v", err)
+	}
+	if _, err := svc.Bind(users[1].ID, in); err != ErrPayoutAccountTaken {
+		t.Fatalf("bind while active: err = %v, want %v", err, ErrPayoutAccountTaken)
+	}
+	if err := svc.Remove(users[0].ID, first.ID); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	second, err := svc.Bind(users[1].ID, in)
+	if err != nil {
+		t.Fatalf("bind after removal: %v", err)
+	}
+	if second.ID == first.ID {
+		t.Errorf("second user reused row %d of the first user", first.ID)
+	}
+	if _, err := svc.Bind(users[0].ID, in); err != ErrPayoutAccountTaken {
+		t.Fatalf("rebind by first user: err = %v, want %v", err, ErrPayoutAccountTaken)
+	}
+}
@@ -3,10 +3,12 @@ package service
 import (
 	"errors"
 	"fmt"
+	"log"
 	"strconv"
 	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
 	"red_packet/backend/internal/models"
 )
@@ -14,119 +16,240 @@ import (
 type ReferralService struct {
 	db        *gorm.DB
 	rewardSvc *RewardService
+	riskSvc   *RiskService
 }
 
-type ReferralStatus struct {
-	MyCode        string        `json:"my_code"`
-	InviteCount   int64         `json:"invite_count"`
-	DirectInvites []models.User `json:"direct_invites"`
+func NewReferralService(db *gorm.DB, rewardSvc *RewardService, riskSvc *RiskService) *ReferralService {
+	return &ReferralService{db: db, rewardSvc: rewardSvc, riskSvc: riskSvc}
 }
 
-func NewReferralService(db *gorm.DB, rewardSvc *RewardService) *ReferralService {
-	return &ReferralService{db: db, rewardSvc: rewardSvc}
+func (s *ReferralService) Bind(userID uint, code string, meta RequestMeta) error {
+	if _, err := s.riskSvc.Check(userID, RiskActionBind, meta); err != nil {
+		return err
+	}
+	inviterCode, err := resolveReferralCode(s.db, normalizeReferralCode(code), time.Now())
+	if err != nil {
+		return err
+	}
+	return s.bindParent(userID, inviterCode.UserID, meta)
 }
 
-func (s *ReferralService) Bind(userID uint, code string) error {
-	return s.db.Transaction(func(tx *gorm.DB) error {
-		var inviterCode models.ReferralCode
-		if err := tx.Where("code = ?", code).First(&inviterCode).Error; err != nil {
-			return ErrReferralCode
+func (s *ReferralService) bindParent(userID, parentID uint, meta RequestMeta) error {
+	if parentID == userID {
+		return ErrBindSelf
+	}
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var locked []models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id IN ?", []uint{userID, parentID}).Order("id").Find(&locked).Error; err != nil {
+			return err
 		}
-		if inviterCode.UserID == userID {
-			return ErrBindSelf
+		if err := s.checkBindRules(tx, userID, parentID, meta, time.Now()); err != nil {
+			return err
+		}
+		ancestors, err := referralAncestors(tx, parentID, userID)
+		if err != nil {
+			return err
 		}
 
-		edge := models.ReferralEdge{ParentUserID: inviterCode.UserID, ChildUserID: userID, Level: 1}
+		edge := models.ReferralEdge{ParentUserID: parentID, ChildUserID: userID, Level: 1}
 		if err := tx.Create(&edge).Error; err != nil {
 			if isDuplicate(err) {
 				return ErrAlreadyBound
 			}
 			return err
 		}
-
-		var parentEdge models.ReferralEdge
-		if err := tx.Where("child_user_id = ? AND level = 1", inviterCode.UserID).First(&parentEdge).Error; err == nil {
-			level2 := models.ReferralEdge{ParentUserID: parentEdge.ParentUserID, ChildUserID: userID, Level: 2}
-			if err := tx.Create(&level2).Error; err != nil && !isDuplicate(err) {
+		maxDepth := referralMaxDepth(tx)
+		for i, ancestorID := range ancestors {
+			level := i + 2
+			if level > maxDepth {
+				break
+			}
+			upper := models.ReferralEdge{ParentUserID: ancestorID, ChildUserID: userID, Level: level}
+			if err := tx.Create(&upper).Error; err != nil && !isDuplicate(err) {
 				return err
 			}
-		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
-			return err
 		}
 		return nil
 	})
+	if err != nil {
+		return err
+	}
+	s.riskSvc.DetectBind(userID, parentID, meta)
+	return nil
 }
 
-func (s *ReferralService) Status(userID uint) (ReferralStatus, error) {
-	var status ReferralStatus
-	var rc models.ReferralCode
-	if err := s.db.Where("user_id = ?", userID).First(&rc).Error; err == nil {
-		status.MyCode = rc.Code
+func (s *ReferralService) checkBindRules(tx *gorm.DB, childID, parentID uint, meta RequestMeta, now time.Time) error {
+	var bound int64
+	if err := tx.Model(&models.ReferralEdge{}).Where("child_user_id = ? AND level = 1", childID).Count(&bound).Error; err != nil {
+		return err
+	}
+	if bound > 0 {
+		return ErrAlreadyBound
 	}
 
-	var childIDs []uint
-	if err := s.db.Model(&models.ReferralEdge{}).
-		Where("parent_user_id = ? AND level = 1", userID).
-		Pluck("child_user_id", &childIDs).Error; err != nil {
-		return status, err
+	var users []models.User
+	if err := tx.Where("id IN ?", []uint{childID, parentID}).Find(&users).Error; err != nil {
+		return err
+	}
+	var child, parent models.User
+	for _, u := range users {
+		if u.ID == childID {
+			child = u
+		} else {
+			parent = u
+		}
+	}
+	if child.ID == 0 || parent.ID == 0 {
+		return ErrReferralCode
 	}
 
-	status.InviteCount = int64(len(childIDs))
-	if len(childIDs) == 0 {
-		return status, nil
+	if hours := loadConfigFloat(tx, "referral_bind_window_hours", 72); hours > 0 {
+		if now.Sub(child.CreatedAt) > time.Duration(hours*float64(time.Hour)) {
+			return ErrBindWindowClosed
+		}
+	}
+	if loadConfigBool(tx, "referral_bind_before_first_task", true) {
+		var claimed int64
+		if err := tx.Model(&models.UserTaskEvent{}).Where("user_id = ?", childID).Count(&claimed).Error; err != nil {
+			return err
+		}
+		if claimed > 0 {
+			return ErrBindAfterTask
+		}
+	}
+	if loadConfigBool(tx, "referral_bind_require_older_inviter", true) && parent.CreatedAt.After(child.CreatedAt) {
+		return ErrBindInviterNewer
 	}
 
-	if err := s.db.Where("id IN ?", childIDs).Order("id DESC").Limit(50).Find(&status.DirectInvites).Error; err != nil {
-		return status, err
+	if loadConfigBool(tx, "referral_bind_reject_shared_device", true) {
+		device := meta.DeviceHash
+		if device == "" {
+			device = child.DeviceHash
+		}
+		if device != "" && (device == parent.DeviceHash || child.DeviceHash != "" && child.DeviceHash == parent.DeviceHash) {
+			return ErrBindSharedDevice
+		}
 	}
-	return status, nil
+	if loadConfigBool(tx, "referral_bind_reject_shared_ip", true) && meta.IP != "" {
+		if meta.IP == parent.RegisterIP {
+			return ErrBindSharedIP
+		}
+		var recent int64
+		if err := tx.Model(&models.RiskDecision{}).
+			Where("user_id = ? AND ip = ? AND created_at >= ?", parentID, meta.IP, now.Add(-24*time.Hour)).
+			Count(&recent).Error; err != nil {
+			return err
+		}
+		if recent > 0 {
+			return ErrBindSharedIP
+		}
+	}
+	return nil
 }
 
 func (s *ReferralService) ProcessFirstValidAction(tx *gorm.DB, childUserID uint) error {
+	_, err := s.processInviteValidity(tx, childUserID)
+	return err
+}
+
+func (s *ReferralService) processInviteValidity(tx *gorm.DB, childUserID uint) (bool, error) {
 	var level1 models.ReferralEdge
 	if err := tx.Where("child_user_id = ? AND level = 1", childUserID).First(&level1).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil
+			return false, nil
 		}
-		return err
+		return false, err
 	}
 	if level1.IsValid {
-		return nil
+		return false, nil
+	}
+
+	// Restricted invitees stay unvalidated; they are re-checked if lifted.
+	if restricted, err := restrictedUserIDs(tx, childUserID); err != nil || restricted[childUserID] {
+		return false, err
 	}
 
 	now := time.Now()
-	level1.IsValid = true
-	level1.ValidatedAt = &now
-	if err := tx.Save(&level1).Error; err != nil {
-		return err
+	progress, err := s.evaluateInviteRules(tx, loadInviteValidRules(tx), childUserID, now, true)
+	if err != nil {
+		return false, err
+	}
+	if progress.Completed < progress.Required {
+		return false, nil
+	}
+	res := tx.Model(&models.ReferralEdge{}).
+		Where("id = ? AND is_valid = ?", level1.ID, false).
+		Updates(map[string]interface{}{"is_valid": true, "validated_at": &now})
+	if res.Error != nil {
+		return false, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return false, nil
 	}
 
-	amountL1, amountL2, err := s.loadInviteRewardRules(tx)
+	var edges []models.ReferralEdge
+	if err := tx.Where("child_user_id = ? AND level <= ?", childUserID, referralMaxDepth(tx)).Order("level").Find(&edges).Error; err != nil {
+		return true, err
+	}
+	ids := make([]uint, 0, len(edges))
+	for _, e := range edges {
+		ids = append(ids, e.ParentUserID)
+	}
+	restricted, err := restrictedUserIDs(tx, ids...)
 	if err != nil {
-		return err
+		return true, err
+	}
+
+	amounts, err := s.loadInviteRewardRules(tx)
+	if err != nil {
+		return true, err
 	}
 	childIDRef := fmt.Sprintf("%d", childUserID)
-	if amountL1 > 0 {
-		if _, err := s.rewardSvc.GrantReward(tx, level1.ParentUserID, amountL1, "invite_valid_l1", childIDRef, "pending"); err != nil {
-			return err
+	for _, e := range edges {
+		if e.Level > len(amounts) || amounts[e.Level-1] <= 0 || restricted[e.ParentUserID] {
+			continue
+		}
+		refType := fmt.Sprintf("invite_valid_l%d", e.Level)
+		if _, err := s.rewardSvc.GrantReward(tx, e.ParentUserID, amounts[e.Level-1], refType, childIDRef, "pending"); err != nil {
+			return true, err
 		}
 	}
-
-	var level2 models.ReferralEdge
-	if err := tx.Where("child_user_id = ? AND level = 2", childUserID).First(&level2).Error; err == nil && amountL2 > 0 {
-		if _, e := s.rewardSvc.GrantReward(tx, level2.ParentUserID, amountL2, "invite_valid_l2", childIDRef, "pending"); e != nil {
-			return e
+	if !restricted[level1.ParentUserID] {
+		if err := s.grantInviteMilestones(tx, level1.ParentUserID); err != nil {
+			return true, err
 		}
-	} else if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
-		return err
 	}
-	return nil
+	return true, nil
 }
 
-func (s *ReferralService) loadInviteRewardRules(tx *gorm.DB) (float64, float64, error) {
+func restrictedUserIDs(tx *gorm.DB, userIDs ...uint) (map[uint]bool, error) {
+	var ids []uint
+	if err := tx.Model(&models.User{}).Where("id IN ? AND restricted = ?", userIDs, true).Pluck("id", &ids).Error; err != nil {
+		return nil, err
+	}
+	out := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		out[id] = true
+	}
+	return out, nil
+}
+
+func (s *ReferralService) loadInviteRewardRules(tx *gorm.DB) ([]float64, error) {
+	maxDepth := referralMaxDepth(tx)
+	var levels []float64
+	if err := jsonUnmarshal(loadConfigString(tx, "invite_reward_levels", ""), &levels); err == nil && len(levels) > 0 {
+		if len(levels) > maxDepth {
+			levels = levels[:maxDepth]
+		}
+		if len(levels) < maxDepth {
+			log.Printf("referral_max_depth is %d but invite_reward_levels has %d levels, deeper levels pay nothing", maxDepth, len(levels))
+		}
+		return levels, nil
+	}
+
 	var rows []models.AppConfig
 	if err := tx.Where("`key` IN ?", []string{"invite_reward_l1", "invite_reward_l2"}).Find(&rows).Error; err != nil {
-		return 0, 0, err
+		return nil, err
 	}
 	l1 := 3.0
 	l2 := 1.0
@@ -142,5 +265,54 @@ func (s *ReferralService) loadInviteRewardRules(tx *gorm.DB) (float64, float64,
 			}
 		}
 	}
-	return l1, l2, nil
+	levels = []float64{l1, l2}
+	if len(levels) > maxDepth {
+		levels = levels[:maxDepth]
+	}
+	if len(levels) < maxDepth {
+		log.Printf("referral_max_depth is %d but invite_reward_levels is not set, levels above %d pay nothing", maxDepth, len(levels))
+	}
+	return levels, nil
+}
+
+const (
+	referralDepthLimit = 10
+	// referralAncestorHopLimit bounds the cycle walk up the level-1 chain.
+	referralAncestorHopLimit = 1000
+)
+
+func referralMaxDepth(db *gorm.DB) int {
+	depth := loadConfigInt(db, "referral_max_depth", 2)
+	if depth < 1 {
+		return 1
+	}
+	if depth > referralDepthLimit {
+		return referralDepthLimit
+	}
+	return depth
+}
+
+func referralAncestors(tx *gorm.DB, parentID, childID uint) ([]uint, error) {
+	var ancestors []uint
+	seen := map[uint]bool{parentID: true}
+	current := parentID
+	for hops := 0; hops < referralAncestorHopLimit; hops++ {
+		var edge models.ReferralEdge
+		if err := tx.Where("child_user_id = ? AND level = 1", current).First(&edge).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ancestors, nil
+			}
+			return nil, err
+		}
+		if edge.ParentUserID == childID {
+			return nil, ErrBindCycle
+		}
+		if seen[edge.ParentUserID] {
+			return ancestors, nil
+		}
+		seen[edge.ParentUserID] = true
+		ancestors = append(ancestors, edge.ParentUserID)
+		current = edge.ParentUserID
+	}
+	return nil, fmt.Errorf("referral chain above user %d exceeds %d hops", parentID, referralAncestorHopLimit)
 }
@@ -0,0 +1,187 @@
+package service
+
+import (
+	"errors"
+	"math"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+
+	"red_packet/backend/internal/models"
+)
+
+type ReferralLanding struct {
+	Code        string `json:"code"`
+	Token       string `json:"token,omitempty"`
+	RedirectURL string `json:"redirect_url"`
+	TTLSeconds  int    `json:"ttl_seconds"`
+}
+
+type ReferralClickStats struct {
+	InviterUserID  uint    `json:"inviter_user_id"`
+	Clicks         int64   `json:"clicks"`
+	UniqueVisitors int64   `json:"unique_visitors"`
+	Registrations  int64   `json:"registrations"`
+	Bound          int64   `json:"bound"`
+	ConversionRate float64 `json:"conversion_rate"`
+}
+
+func (s *ReferralService) attributionWindow() time.Duration {
+	return time.Duration(loadConfigFloat(s.db, "referral_attribution_window_hours", 72) * float64(time.Hour))
+}
+
+func (s *ReferralService) RecordClick(code string, meta RequestMeta) (ReferralLanding, error) {
+	code = normalizeReferralCode(code)
+	landing := ReferralLanding{Code: code, TTLSeconds: int(s.attributionWindow().Seconds())}
+	base := loadConfigString(s.db, "referral_landing_url", "/")
+
+	inviterCode, err := resolveReferralCode(s.db, code, time.Now())
+	if err != nil {
+		landing.RedirectURL = base
+		return landing, err
+	}
+
+	token, err := randomHex(16)
+	if err != nil {
+		return landing, err
+	}
+	click := models.ReferralClick{
+		Code:          inviterCode.Code,
+		InviterUserID: inviterCode.UserID,
+		Token:         token,
+		IP:            meta.IP,
+		UserAgent:     truncateString(meta.UserAgent, 255),
+		DeviceHash:    meta.DeviceHash,
+	}
+	if err := s.db.Create(&click).Error; err != nil {
+		return landing, err
+	}
+	landing.Token = click.Token
+	landing.RedirectURL = appendQuery(base, url.Values{"invite_code": {click.Code}, "attr": {click.Token}})
+	return landing, nil
+}
+
+func (s *ReferralService) AttributeRegistration(userID uint, token string, meta RequestMeta) (bool, error) {
+	now := time.Now()
+	since := now.Add(-s.attributionWindow())
+	var click models.ReferralClick
+	q := s.db.Where("registered_user_id IS NULL AND created_at >= ?", since)
+	switch {
+	case token != "":
+		q = q.Where("token = ?", token)
+	case meta.DeviceHash != "" && meta.IP != "" && loadConfigBool(s.db, "referral_attribution_device_fallback", true):
+		// The device hash is client-supplied, so the fallback also requires the
+		// click to come from the same trusted client IP.
+		q = q.Where("device_hash = ? AND ip = ?", meta.DeviceHash, meta.IP).Order("id DESC")
+	default:
+		return false, nil
+	}
+	if err := q.First(&click).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	res := s.db.Model(&models.ReferralClick{}).
+		Where("id = ? AND registered_user_id IS NULL", click.ID).
+		Updates(map[string]interface{}{"registered_user_id": userID, "converted_at": &now})
+	if res.Error != nil {
+		if isDuplicate(res.Error) {
+			return false, nil
+		}
+		return false, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return false, nil
+	}
+
+	bindErr := func() error {
+		if _, err := s.riskSvc.Check(userID, RiskActionBind, meta); err != nil {
+			return err
+		}
+		return s.bindParent(userID, click.InviterUserID, meta)
+	}()
+	if bindErr != nil {
+		// A rejected bind is not a conversion: release the click so it neither
+		// counts as a registration nor stays consumed.
+		if err := s.releaseClick(click.ID, userID); err != nil {
+			return false, err
+		}
+		return false, bindErr
+	}
+	if err := s.db.Model(&models.ReferralClick{}).Where("id = ?", click.ID).Update("bound", true).Error; err != nil {
+		return true, err
+	}
+	return true, nil
+}
+
+func (s *ReferralService) releaseClick(clickID, userID uint) error {
+	return s.db.Model(&models.ReferralClick{}).
+		Where("id = ? AND registered_user_id = ?", clickID, userID).
+		Updates(map[string]interface{}{"registered_user_id": nil, "converted_at": nil}).Error
+}
+
+func (s *ReferralService) ClickStats(inviterID uint, days int) (ReferralClickStats, error) {
+	items, err := s.clickStats(inviterID, days, 1)
+	if err != nil || len(items) == 0 {
+		return ReferralClickStats{InviterUserID: inviterID}, err
+	}
+	return items[0], nil
+}
+
+func (s *ReferralService) TopClickStats(days, limit int) ([]ReferralClickStats, error) {
+	if limit < 1 || limit > 100 {
+		limit = 50
+	}
+	return s.clickStats(0, days, limit)
+}
+
+func (s *ReferralService) clickStats(inviterID uint, days, limit int) ([]ReferralClickStats, error) {
+	if days < 1 || days > 365 {
+		days = 30
+	}
+	q := s.db.Model(&models.ReferralClick{}).
+		Select("inviter_user_id, COUNT(*) AS clicks, "+
+			"COUNT(DISTINCT CASE WHEN device_hash <> '' THEN device_hash ELSE ip END) AS unique_visitors, "+
+			"COUNT(registered_user_id) AS registrations, "+
+			"SUM(CASE WHEN bound THEN 1 ELSE 0 END) AS bound").
+		Where("created_at >= ?", time.Now().AddDate(0, 0, -days)).
+		Group("inviter_user_id").
+		Order("clicks DESC").
+		Limit(limit)
+	if inviterID > 0 {
+		q = q.Where("inviter_user_id = ?", inviterID)
+	}
+	var items []ReferralClickStats
+	if err := q.Scan(&items).Error; err != nil {
+		return nil, err
+	}
+	for i := range items {
+		if items[i].UniqueVisitors > 0 {
+			items[i].ConversionRate = math.Round(float64(items[i].Registrations)/float64(items[i].UniqueVisitors)*10000) / 10000
+		}
+	}
+	return items, nil
+}
+
+func appendQuery(base string, values url.Values) string {
+	sep := "?"
+	if strings.Contains(base, "?") {
+		sep = "&"
+	}
+	return base + sep + values.Encode()
+}
+
+// truncateString keeps at most n runes (varchar length is in characters) and
+// drops invalid UTF-8 so utf8mb4 columns accept the value.
+func truncateString(v string, n int) string {
+	v = strings.ToValidUTF8(v, "")
+	if utf8.RuneCountInString(v) <= n {
+		return v
+	}
+	return string([]rune(v)[:n])
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"red_packet/backend/internal/models"
+)
+
+func TestTruncateString(t *testing.T) {
+	cases := []struct {
+		in   string
+		n    int
+		want string
+	}{
+		{"Mozilla/5.0", 255, "Mozilla/5.0"},
+		{"abcdef", 3, "abc"},
+		{"日本語のUA", 3, "日本語"},
+		{"a😀b", 2, "a😀"},
+		{"ok\xffbad", 10, "okbad"},
+	}
+	for _, c := range cases {
+		got := truncateString(c.in, c.n)
+		if got != c.want {
+			t.Errorf("truncateString(%q, %d) = %q, want %q", c.in, c.n, got, c.want)
+		}
+		if !utf8.ValidString(got) {
+			t.Errorf("truncateString(%q, %d) returned invalid UTF-8", c.in, c.n)
+		}
+	}
+	long := strings.Repeat("汉", 300)
+	if got := truncateString(long, 255); utf8.RuneCountInString(got) != 255 {
+		t.Errorf("rune count = %d, want 255", utf8.RuneCountInString(got))
+	}
+}
+
+func TestAttributeRegistrationReleasesClickOnFailedBind(t *testing.T) {
+	db := openTestDB(t)
+	svcs := newTestContainer(db)
+	user := models.User{DeviceHash: fmt.Sprintf("attr-%d", time.Now().UnixNano())}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	click := models.ReferralClick{Code: "SELF", InviterUserID: user.ID, Token: user.DeviceHash, IP: "203.0.113.9", DeviceHash: user.DeviceHash}
+	if err := db.Create(&click).Error; err != nil {
+		t.Fatalf("create click: %v", err)
+	}
+
+	ok, err := svcs.Referral.AttributeRegistration(user.ID, click.Token, RequestMeta{IP: click.IP, DeviceHash: click.DeviceHash})
+	if ok || !errors.Is(err, ErrBindSelf) {
+		t.Fatalf("attribute = %v, %v; want false, ErrBindSelf", ok, err)
+	}
+	var got models.ReferralClick
+	if err := db.First(&got, click.ID).Error; err != nil {
+		t.Fatalf("reload click: %v", err)
+	}
+	if got.RegisteredUserID != nil || got.ConvertedAt != nil || got.Bound {
+		t.Errorf("click after failed bind = %+v, want unconverted", got)
+	}
+	stats, err := svcs.Referral.ClickStats(user.ID, 1)
+	if err != nil {
+		t.Fatalf("stats: %v", err)
+	}
+	if stats.Registrations != 0 || stats.Bound != 0 {
+		t.Errorf("stats = %+v, want no registrations", stats)
+	}
+}
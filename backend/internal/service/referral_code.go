@@ -0,0 +1,202 @@
+package service
+
+import (
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"regexp"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"red_packet/backend/internal/models"
+)
+
+const referralCodeAlphabet = "23456789ABCDEFGHJKMNPQRSTUVWXYZ"
+
+var vanityCodePattern = regexp.MustCompile(`^[A-Z0-9]{4,16}$`)
+
+func generateReferralCode(bodyLen int) (string, error) {
+	if bodyLen < 4 {
+		bodyLen = 4
+	}
+	max := big.NewInt(int64(len(referralCodeAlphabet)))
+	buf := make([]byte, bodyLen, bodyLen+1)
+	for i := range buf {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		buf[i] = referralCodeAlphabet[n.Int64()]
+	}
+	return string(append(buf, referralCodeChecksum(string(buf)))), nil
+}
+
+func referralCodeChecksum(body string) byte {
+	sum := 0
+	for i := 0; i < len(body); i++ {
+		sum += (i + 1) * strings.IndexByte(referralCodeAlphabet, body[i])
+	}
+	return referralCodeAlphabet[sum%len(referralCodeAlphabet)]
+}
+
+func isGeneratedReferralCodeShape(code string) bool {
+	if len(code) < 5 {
+		return false
+	}
+	for i := 0; i < len(code); i++ {
+		if strings.IndexByte(referralCodeAlphabet, code[i]) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func validReferralCodeChecksum(code string) bool {
+	if !isGeneratedReferralCodeShape(code) {
+		return false
+	}
+	return referralCodeChecksum(code[:len(code)-1]) == code[len(code)-1]
+}
+
+func normalizeReferralCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+func issueReferralCode(tx *gorm.DB, userID uint, kind, code string) (models.ReferralCode, error) {
+	now := time.Now()
+	if err := tx.Model(&models.ReferralCode{}).
+		Where("active_user_id = ?", userID).
+		Updates(map[string]interface{}{"active_user_id": nil, "retired_at": &now}).Error; err != nil {
+		return models.ReferralCode{}, err
+	}
+	owner := userID
+	item := models.ReferralCode{UserID: userID, Kind: kind, ActiveUserID: &owner}
+	if code != "" {
+		item.Code = code
+		if err := tx.Create(&item).Error; err != nil {
+			if isDuplicate(err) {
+				return models.ReferralCode{}, ErrReferralCodeTaken
+			}
+			return models.ReferralCode{}, err
+		}
+		return item, nil
+	}
+
+	length := loadConfigInt(tx, "referral_code_length", 7)
+	for attempt := 0; attempt < 8; attempt++ {
+		generated, err := generateReferralCode(length)
+		if err != nil {
+			return models.ReferralCode{}, err
+		}
+		item.ID = 0
+		item.Code = generated
+		err = tx.Create(&item).Error
+		if err == nil {
+			return item, nil
+		}
+		if !isDuplicate(err) {
+			return models.ReferralCode{}, err
+		}
+	}
+	return models.ReferralCode{}, ErrReferralCodeExhausted
+}
+
+func resolveReferralCode(tx *gorm.DB, code string, now time.Time) (models.ReferralCode, error) {
+	var item models.ReferralCode
+	if err := tx.Where("code = ?", code).First(&item).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return item, err
+		}
+		if isGeneratedReferralCodeShape(code) && !validReferralCodeChecksum(code) {
+			return item, ErrReferralCodeChecksum
+		}
+		return item, ErrReferralCode
+	}
+	if item.ActiveUserID == nil {
+		grace := loadConfigFloat(tx, "referral_code_retired_grace_hours", 720)
+		if item.RetiredAt == nil || now.Sub(*item.RetiredAt) > time.Duration(grace*float64(time.Hour)) {
+			return item, ErrReferralCode
+		}
+	}
+	return item, nil
+}
+
+func (s *ReferralService) CurrentCode(userID uint) (models.ReferralCode, error) {
+	var item models.ReferralCode
+	err := s.db.Where("active_user_id = ?", userID).First(&item).Error
+	return item, err
+}
+
+func (s *ReferralService) RotateCode(userID uint) (models.ReferralCode, error) {
+	var item models.ReferralCode
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var current models.ReferralCode
+		err := tx.Where("active_user_id = ?", userID).First(&current).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if err == nil && current.Kind != "legacy" {
+			cooldown := loadConfigFloat(tx, "referral_code_rotate_cooldown_hours", 24)
+			if time.Since(current.CreatedAt) < time.Duration(cooldown*float64(time.Hour)) {
+				return ErrReferralCodeCooldown
+			}
+		}
+		item, err = issueReferralCode(tx, userID, "generated", "")
+		return err
+	})
+	return item, err
+}
+
+func (s *ReferralService) AdminRotateCode(userID uint) (models.ReferralCode, error) {
+	var item models.ReferralCode
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		item, err = issueReferralCode(tx, userID, "generated", "")
+		return err
+	})
+	return item, err
+}
+
+func (s *ReferralService) SetVanityCode(userID uint, code string) (models.ReferralCode, error) {
+	code = normalizeReferralCode(code)
+	if !vanityCodePattern.MatchString(code) || validReferralCodeChecksum(code) {
+		return models.ReferralCode{}, ErrReferralCodeFormat
+	}
+	var item models.ReferralCode
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.User{}).Where("id = ?", userID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return ErrUserNotFound
+		}
+		var err error
+		item, err = issueReferralCode(tx, userID, "vanity", code)
+		return err
+	})
+	return item, err
+}
+
+func (s *ReferralService) RotateLegacyCodes(limit int) (int, error) {
+	if limit < 1 || limit > 1000 {
+		limit = 200
+	}
+	var userIDs []uint
+	if err := s.db.Model(&models.ReferralCode{}).
+		Where("kind = ? AND active_user_id IS NOT NULL", "legacy").
+		Limit(limit).
+		Pluck("user_id", &userIDs).Error; err != nil {
+		return 0, err
+	}
+	rotated := 0
+	for _, uid := range userIDs {
+		if _, err := s.AdminRotateCode(uid); err != nil {
+			return rotated, err
+		}
+		rotated++
+	}
+	return rotated, nil
+}
@@ -0,0 +1,300 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+
+	"red_packet/backend/internal/models"
+)
+
+const (
+	LeaderboardDaily  = "daily"
+	LeaderboardWeekly = "weekly"
+)
+
+type InviteMilestone struct {
+	Count int     `json:"count"`
+	Bonus float64 `json:"bonus"`
+}
+
+type InviteMilestoneView struct {
+	InviteMilestone
+	Reached bool `json:"reached"`
+}
+
+type LeaderboardPeriodConfig struct {
+	Enabled bool      `json:"enabled"`
+	Prizes  []float64 `json:"prizes"`
+}
+
+type LeaderboardConfig struct {
+	TopN   int                     `json:"top_n"`
+	Daily  LeaderboardPeriodConfig `json:"daily"`
+	Weekly LeaderboardPeriodConfig `json:"weekly"`
+}
+
+type LeaderboardEntry struct {
+	Rank    int     `json:"rank"`
+	UserID  uint    `json:"user_id,omitempty"`
+	Account string  `json:"account"`
+	Count   int64   `json:"count"`
+	Prize   float64 `json:"prize"`
+}
+
+type Leaderboard struct {
+	PeriodType string             `json:"period_type"`
+	PeriodKey  string             `json:"period_key"`
+	StartAt    time.Time          `json:"start_at"`
+	EndAt      time.Time          `json:"end_at"`
+	Settled    bool               `json:"settled"`
+	Entries    []LeaderboardEntry `json:"entries"`
+}
+
+func loadInviteMilestones(db *gorm.DB) []InviteMilestone {
+	var items []InviteMilestone
+	_ = jsonUnmarshal(loadConfigString(db, "invite_milestones", ""), &items)
+	return items
+}
+
+func (s *ReferralService) LeaderboardConfig() LeaderboardConfig {
+	cfg := LeaderboardConfig{TopN: 20}
+	_ = jsonUnmarshal(loadConfigString(s.db, "invite_leaderboard", ""), &cfg)
+	if cfg.TopN < 1 || cfg.TopN > 100 {
+		cfg.TopN = 20
+	}
+	return cfg
+}
+
+func (s *ReferralService) grantInviteMilestones(tx *gorm.DB, parentID uint) error {
+	milestones := loadInviteMilestones(tx)
+	if len(milestones) == 0 {
+		return nil
+	}
+	var valid int64
+	if err := tx.Model(&models.ReferralEdge{}).
+		Joins("JOIN users AS children ON children.id = referral_edges.child_user_id").
+		Where("referral_edges.parent_user_id = ? AND referral_edges.level = 1 AND referral_edges.is_valid = ?", parentID, true).
+		Where("children.restricted = ?", false).
+		Count(&valid).Error; err != nil {
+		return err
+	}
+	for _, m := range milestones {
+		if m.Count <= 0 || m.Bonus <= 0 || valid < int64(m.Count) {
+			continue
+		}
+		if _, err := s.rewardSvc.GrantReward(tx, parentID, m.Bonus, "invite_milestone", fmt.Sprintf("%d", m.Count), "pending"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *ReferralService) milestoneViews(validCount int64) []InviteMilestoneView {
+	milestones := loadInviteMilestones(s.db)
+	out := make([]InviteMilestoneView, 0, len(milestones))
+	for _, m := range milestones {
+		out = append(out, InviteMilestoneView{InviteMilestone: m, Reached: validCount >= int64(m.Count)})
+	}
+	return out
+}
+
+func leaderboardPeriod(periodType string, t time.Time) (time.Time, time.Time, string) {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	if periodType == LeaderboardWeekly {
+		offset := (int(day.Weekday()) + 6) % 7
+		start := day.AddDate(0, 0, -offset)
+		year, week := start.ISOWeek()
+		return start, start.AddDate(0, 0, 7), fmt.Sprintf("%d-W%02d", year, week)
+	}
+	return day, day.AddDate(0, 0, 1), day.Format("2006-01-02")
+}
+
+func previousLeaderboardPeriod(periodType string, now time.Time) (time.Time, time.Time, string) {
+	start, _, _ := leaderboardPeriod(periodType, now)
+	return leaderboardPeriod(periodType, start.Add(-time.Second))
+}
+
+func (s *ReferralService) leaderboardStandings(db *gorm.DB, start, end time.Time, limit int, prizes []float64) ([]LeaderboardEntry, error) {
+	type row struct {
+		UserID uint
+		Cnt    int64
+		LastAt time.Time
+	}
+	var rows []row
+	if err := db.Table("referral_edges").
+		Select("referral_edges.parent_user_id AS user_id, COUNT(*) AS cnt, MAX(referral_edges.validated_at) AS last_at").
+		Joins("JOIN users ON users.id = referral_edges.parent_user_id").
+		Joins("JOIN users AS children ON children.id = referral_edges.child_user_id").
+		Where("referral_edges.level = 1 AND referral_edges.is_valid = ? AND referral_edges.validated_at >= ? AND referral_edges.validated_at < ?", true, start, end).
+		Where("users.restricted = ? AND children.restricted = ?", false, false).
+		Group("referral_edges.parent_user_id").
+		Order("cnt DESC, last_at ASC").
+		Limit(limit).
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return []LeaderboardEntry{}, nil
+	}
+	ids := make([]uint, 0, len(rows))
+	for _, r := range rows {
+		ids = append(ids, r.UserID)
+	}
+	var users []models.User
+	if err := db.Where("id IN ?", ids).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	accounts := make(map[uint]string, len(users))
+	for _, u := range users {
+		accounts[u.ID] = maskUserAccount(u)
+	}
+	out := make([]LeaderboardEntry, 0, len(rows))
+	for i, r := range rows {
+		entry := LeaderboardEntry{Rank: i + 1, UserID: r.UserID, Account: accounts[r.UserID], Count: r.Cnt}
+		if i < len(prizes) {
+			entry.Prize = prizes[i]
+		}
+		out = append(out, entry)
+	}
+	return out, nil
+}
+
+func (s *ReferralService) Leaderboard(periodType string, previous bool) (Leaderboard, error) {
+	if periodType != LeaderboardWeekly {
+		periodType = LeaderboardDaily
+	}
+	cfg := s.LeaderboardConfig()
+	periodCfg := cfg.Daily
+	if periodType == LeaderboardWeekly {
+		periodCfg = cfg.Weekly
+	}
+	now := time.Now()
+	start, end, key := leaderboardPeriod(periodType, now)
+	if previous {
+		start, end, key = previousLeaderboardPeriod(periodType, now)
+	}
+	board := Leaderboard{PeriodType: periodType, PeriodKey: key, StartAt: start, EndAt: end}
+
+	var settlement models.LeaderboardSettlement
+	err := s.db.Where("period_type = ? AND period_key = ?", periodType, key).First(&settlement).Error
+	if err == nil {
+		board.Settled = true
+		if err := json.Unmarshal([]byte(settlement.Standings), &board.Entries); err != nil {
+			return board, err
+		}
+	} else {
+		entries, err := s.leaderboardStandings(s.db, start, end, cfg.TopN, periodCfg.Prizes)
+		if err != nil {
+			return board, err
+		}
+		board.Entries = entries
+	}
+	for i := range board.Entries {
+		board.Entries[i].UserID = 0
+	}
+	return board, nil
+}
+
+// leaderboardCatchUp bounds how many missed periods one run settles.
+var leaderboardCatchUp = map[string]int{LeaderboardDaily: 31, LeaderboardWeekly: 5}
+
+func (s *ReferralService) SettleLeaderboard(periodType string, now time.Time) (int, error) {
+	cfg := s.LeaderboardConfig()
+	periodCfg := cfg.Daily
+	if periodType == LeaderboardWeekly {
+		periodCfg = cfg.Weekly
+	}
+	if !periodCfg.Enabled {
+		return 0, nil
+	}
+	pending, err := s.unsettledLeaderboardPeriods(periodType, now)
+	if err != nil {
+		return 0, err
+	}
+	paid := 0
+	for i := len(pending) - 1; i >= 0; i-- {
+		n, err := s.settleLeaderboardPeriod(periodType, pending[i], cfg.TopN, periodCfg.Prizes)
+		if err != nil {
+			return paid, err
+		}
+		paid += n
+	}
+	return paid, nil
+}
+
+// unsettledLeaderboardPeriods walks back from the previous period until it
+// reaches a settled one, newest first. Before any settlement exists only the
+// previous period is due.
+func (s *ReferralService) unsettledLeaderboardPeriods(periodType string, now time.Time) ([]time.Time, error) {
+	var settledAny int64
+	if err := s.db.Model(&models.LeaderboardSettlement{}).Where("period_type = ?", periodType).Count(&settledAny).Error; err != nil {
+		return nil, err
+	}
+	var out []time.Time
+	at := now
+	for i := 0; i < leaderboardCatchUp[periodType]; i++ {
+		start, _, key := previousLeaderboardPeriod(periodType, at)
+		var settled int64
+		if err := s.db.Model(&models.LeaderboardSettlement{}).Where("period_type = ? AND period_key = ?", periodType, key).Count(&settled).Error; err != nil {
+			return nil, err
+		}
+		if settled > 0 {
+			break
+		}
+		out = append(out, start)
+		if settledAny == 0 {
+			break
+		}
+		at = start
+	}
+	return out, nil
+}
+
+func (s *ReferralService) settleLeaderboardPeriod(periodType string, at time.Time, topN int, prizes []float64) (int, error) {
+	start, end, key := leaderboardPeriod(periodType, at)
+	paid := 0
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		settlement := models.LeaderboardSettlement{PeriodType: periodType, PeriodKey: key, Standings: "[]"}
+		if err := tx.Create(&settlement).Error; err != nil {
+			if isDuplicate(err) {
+				return nil
+			}
+			return err
+		}
+		entries, err := s.leaderboardStandings(tx, start, end, topN, prizes)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			if e.Prize <= 0 {
+				continue
+			}
+			refID := fmt.Sprintf("%s:%d", key, e.Rank)
+			if _, err := s.rewardSvc.GrantReward(tx, e.UserID, e.Prize, "leaderboard_"+periodType, refID, "pending"); err != nil {
+				return err
+			}
+			paid++
+		}
+		raw, err := json.Marshal(entries)
+		if err != nil {
+			return err
+		}
+		return tx.Model(&models.LeaderboardSettlement{}).Where("id = ?", settlement.ID).Update("standings", string(raw)).Error
+	})
+	return paid, err
+}
+
+func (s *ReferralService) SettleLeaderboards(now time.Time) (int, error) {
+	total := 0
+	for _, periodType := range []string{LeaderboardDaily, LeaderboardWeekly} {
+		paid, err := s.SettleLeaderboard(periodType, now)
+		if err != nil {
+			return total, err
+		}
+		total += paid
+	}
+	return total, nil
+}
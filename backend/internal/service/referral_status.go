@@ -0,0 +1,197 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"red_packet/backend/internal/models"
+)
+
+type ReferralValidProgress struct {
+	Completed int                  `json:"completed"`
+	Required  int                  `json:"required"`
+	Checks    []ReferralValidCheck `json:"checks"`
+}
+
+type ReferralInvitee struct {
+	UserID       uint                  `json:"user_id"`
+	Account      string                `json:"account"`
+	Level        int                   `json:"level"`
+	BoundAt      time.Time             `json:"bound_at"`
+	IsValid      bool                  `json:"is_valid"`
+	ValidatedAt  *time.Time            `json:"validated_at"`
+	Progress     ReferralValidProgress `json:"progress"`
+	Earned       float64               `json:"earned"`
+	RewardStatus string                `json:"reward_status"`
+}
+
+type ReferralStatus struct {
+	MyCode        string                `json:"my_code"`
+	InviteCount   int64                 `json:"invite_count"`
+	ValidCount    int64                 `json:"valid_count"`
+	Level2Count   int64                 `json:"level2_count"`
+	EarnedTotal   float64               `json:"earned_total"`
+	EarnedByLevel map[string]float64    `json:"earned_by_level"`
+	Milestones    []InviteMilestoneView `json:"milestones"`
+	Level         int                   `json:"level"`
+	Page          int                   `json:"page"`
+	Size          int                   `json:"size"`
+	Total         int64                 `json:"total"`
+	Invitees      []ReferralInvitee     `json:"invitees"`
+}
+
+func (s *ReferralService) Status(userID uint, level, page, size int) (ReferralStatus, error) {
+	if level < 1 || level > referralMaxDepth(s.db) {
+		level = 1
+	}
+	if page < 1 {
+		page = 1
+	}
+	if size < 1 || size > 100 {
+		size = 20
+	}
+	status := ReferralStatus{Level: level, Page: page, Size: size, EarnedByLevel: map[string]float64{}, Invitees: []ReferralInvitee{}}
+	if rc, err := s.CurrentCode(userID); err == nil {
+		status.MyCode = rc.Code
+	}
+
+	if err := s.db.Model(&models.ReferralEdge{}).Where("parent_user_id = ? AND level = 1", userID).Count(&status.InviteCount).Error; err != nil {
+		return status, err
+	}
+	if err := s.db.Model(&models.ReferralEdge{}).Where("parent_user_id = ? AND level = 1 AND is_valid = ?", userID, true).Count(&status.ValidCount).Error; err != nil {
+		return status, err
+	}
+	if err := s.db.Model(&models.ReferralEdge{}).Where("parent_user_id = ? AND level = 2", userID).Count(&status.Level2Count).Error; err != nil {
+		return status, err
+	}
+	status.Milestones = s.milestoneViews(status.ValidCount)
+
+	type earnedRow struct {
+		SourceType string
+		Total      float64
+	}
+	var earned []earnedRow
+	if err := s.db.Model(&models.Reward{}).
+		Select("source_type, SUM(amount) AS total").
+		Where("user_id = ? AND source_type LIKE ?", userID, "invite_valid_l%").
+		Group("source_type").
+		Scan(&earned).Error; err != nil {
+		return status, err
+	}
+	for _, r := range earned {
+		key := strings.TrimPrefix(r.SourceType, "invite_valid_")
+		status.EarnedByLevel[key] = round2(r.Total)
+		status.EarnedTotal += r.Total
+	}
+	status.EarnedTotal = round2(status.EarnedTotal)
+
+	q := s.db.Model(&models.ReferralEdge{}).Where("parent_user_id = ? AND level = ?", userID, level)
+	if err := q.Count(&status.Total).Error; err != nil {
+		return status, err
+	}
+	var edges []models.ReferralEdge
+	if err := q.Order("id DESC").Offset((page - 1) * size).Limit(size).Find(&edges).Error; err != nil {
+		return status, err
+	}
+	if len(edges) == 0 {
+		return status, nil
+	}
+
+	childIDs := make([]uint, 0, len(edges))
+	childRefs := make([]string, 0, len(edges))
+	for _, e := range edges {
+		childIDs = append(childIDs, e.ChildUserID)
+		childRefs = append(childRefs, fmt.Sprintf("%d", e.ChildUserID))
+	}
+
+	var users []models.User
+	if err := s.db.Where("id IN ?", childIDs).Find(&users).Error; err != nil {
+		return status, err
+	}
+	accounts := make(map[uint]string, len(users))
+	for _, u := range users {
+		accounts[u.ID] = maskUserAccount(u)
+	}
+
+	var direct []models.ReferralEdge
+	if err := s.db.Where("child_user_id IN ? AND level = 1", childIDs).Find(&direct).Error; err != nil {
+		return status, err
+	}
+	validity := make(map[uint]models.ReferralEdge, len(direct))
+	for _, e := range direct {
+		validity[e.ChildUserID] = e
+	}
+
+	progress, err := s.validProgress(childIDs)
+	if err != nil {
+		return status, err
+	}
+
+	var rewards []models.Reward
+	if err := s.db.Where("user_id = ? AND source_type = ? AND source_id IN ?", userID, fmt.Sprintf("invite_valid_l%d", level), childRefs).
+		Find(&rewards).Error; err != nil {
+		return status, err
+	}
+	rewardByChild := make(map[string]models.Reward, len(rewards))
+	for _, r := range rewards {
+		rewardByChild[r.SourceID] = r
+	}
+
+	for _, e := range edges {
+		v := validity[e.ChildUserID]
+		item := ReferralInvitee{
+			UserID:      e.ChildUserID,
+			Account:     accounts[e.ChildUserID],
+			Level:       e.Level,
+			BoundAt:     e.CreatedAt,
+			IsValid:     v.IsValid,
+			ValidatedAt: v.ValidatedAt,
+			Progress:    progress[e.ChildUserID],
+		}
+		if r, ok := rewardByChild[fmt.Sprintf("%d", e.ChildUserID)]; ok {
+			item.Earned = round2(r.Amount)
+			item.RewardStatus = r.Status
+		}
+		if item.IsValid {
+			item.Progress.Completed = item.Progress.Required
+			for i := range item.Progress.Checks {
+				item.Progress.Checks[i].Passed = true
+			}
+		}
+		status.Invitees = append(status.Invitees, item)
+	}
+	return status, nil
+}
+
+func (s *ReferralService) validProgress(childIDs []uint) (map[uint]ReferralValidProgress, error) {
+	rules := loadInviteValidRules(s.db)
+	now := time.Now()
+	out := make(map[uint]ReferralValidProgress, len(childIDs))
+	for _, id := range childIDs {
+		progress, err := s.evaluateInviteRules(s.db, rules, id, now, false)
+		if err != nil {
+			return nil, err
+		}
+		out[id] = progress
+	}
+	return out, nil
+}
+
+func maskUserAccount(u models.User) string {
+	if phone := derefString(u.Phone); phone != "" {
+		runes := []rune(phone)
+		if len(runes) <= 7 {
+			return maskAccountNo(phone)
+		}
+		return string(runes[:3]) + strings.Repeat("*", len(runes)-7) + string(runes[len(runes)-4:])
+	}
+	if email := derefString(u.Email); email != "" {
+		at := strings.LastIndex(email, "@")
+		if at <= 0 {
+			return maskName(email)
+		}
+		return maskName(email[:at]) + email[at:]
+	}
+	return fmt.Sprintf("user#%d", u.ID)
+}
@@ -0,0 +1,133 @@
+package service
+
+import (
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+
+	"red_packet/backend/internal/models"
+)
+
+type InviteValidRules struct {
+	MinDistinctTasks  int      `json:"min_distinct_tasks"`
+	TaskTypes         []string `json:"task_types"`
+	MinSpins          int      `json:"min_spins"`
+	MinAccountAgeDays float64  `json:"min_account_age_days"`
+	RequireRiskPass   bool     `json:"require_risk_pass"`
+}
+
+type ReferralValidCheck struct {
+	Rule    string  `json:"rule"`
+	Current float64 `json:"current"`
+	Target  float64 `json:"target"`
+	Passed  bool    `json:"passed"`
+}
+
+var defaultInviteValidRules = InviteValidRules{MinDistinctTasks: 1}
+
+func loadInviteValidRules(db *gorm.DB) InviteValidRules {
+	rules := defaultInviteValidRules
+	var custom InviteValidRules
+	if err := jsonUnmarshal(loadConfigString(db, "invite_valid_rules", ""), &custom); err == nil {
+		rules = custom
+	}
+	return rules
+}
+
+func (s *ReferralService) InviteValidRules() InviteValidRules {
+	return loadInviteValidRules(s.db)
+}
+
+func (s *ReferralService) evaluateInviteRules(tx *gorm.DB, rules InviteValidRules, childID uint, now time.Time, withRisk bool) (ReferralValidProgress, error) {
+	var progress ReferralValidProgress
+	add := func(rule string, current, target float64) {
+		check := ReferralValidCheck{Rule: rule, Current: current, Target: target, Passed: current >= target}
+		progress.Checks = append(progress.Checks, check)
+		progress.Required++
+		if check.Passed {
+			progress.Completed++
+		}
+	}
+
+	if rules.MinDistinctTasks > 0 {
+		q := tx.Model(&models.UserTaskEvent{}).Where("user_task_events.user_id = ?", childID)
+		if len(rules.TaskTypes) > 0 {
+			q = q.Joins("JOIN tasks ON tasks.id = user_task_events.task_id").Where("tasks.type IN ?", rules.TaskTypes)
+		}
+		var count int64
+		if err := q.Distinct("user_task_events.task_id").Count(&count).Error; err != nil {
+			return progress, err
+		}
+		add("distinct_tasks", float64(count), float64(rules.MinDistinctTasks))
+	}
+	if rules.MinSpins > 0 {
+		var count int64
+		if err := tx.Model(&models.SpinRecord{}).Where("user_id = ?", childID).Count(&count).Error; err != nil {
+			return progress, err
+		}
+		add("spins", float64(count), float64(rules.MinSpins))
+	}
+	if rules.MinAccountAgeDays > 0 {
+		var user models.User
+		if err := tx.Select("id", "created_at").First(&user, childID).Error; err != nil {
+			return progress, err
+		}
+		add("account_age_days", round2(now.Sub(user.CreatedAt).Hours()/24), rules.MinAccountAgeDays)
+	}
+	if rules.RequireRiskPass {
+		passed := 0.0
+		if withRisk && progress.Completed == progress.Required {
+			decision, err := s.riskSvc.Evaluate(childID, RiskActionInviteValid, RequestMeta{})
+			if err != nil {
+				return progress, err
+			}
+			if decision.Decision == RiskAllow {
+				passed = 1
+			}
+		}
+		add("risk_check", passed, 1)
+	}
+	return progress, nil
+}
+
+func (s *ReferralService) CheckInviteValidity(childID uint) (bool, error) {
+	validated := false
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		validated, err = s.processInviteValidity(tx, childID)
+		return err
+	})
+	return validated, err
+}
+
+func (s *ReferralService) ValidatePendingInvites(now time.Time) (int, error) {
+	days := loadConfigFloat(s.db, "invite_valid_pending_days", 30)
+	recheck := time.Duration(loadConfigFloat(s.db, "invite_valid_recheck_minutes", 60) * float64(time.Minute))
+	// Least recently checked first so every pending edge gets its turn; the
+	// recheck interval also bounds risk evaluations per child.
+	var edges []models.ReferralEdge
+	if err := s.db.Select("id", "child_user_id").
+		Where("level = 1 AND is_valid = ? AND created_at >= ?", false, now.Add(-time.Duration(days*24*float64(time.Hour)))).
+		Where("checked_at IS NULL OR checked_at < ?", now.Add(-recheck)).
+		Order("checked_at IS NOT NULL, checked_at, id").
+		Limit(500).
+		Find(&edges).Error; err != nil {
+		return 0, err
+	}
+	validated := 0
+	for _, e := range edges {
+		if err := s.db.Model(&models.ReferralEdge{}).Where("id = ?", e.ID).Update("checked_at", now).Error; err != nil {
+			return validated, err
+		}
+		ok, err := s.CheckInviteValidity(e.ChildUserID)
+		if err != nil {
+			log.Printf("invite validity for user %d: %v", e.ChildUserID, err)
+			continue
+		}
+		if ok {
+			validated++
+		}
+	}
+	return validated, nil
+}
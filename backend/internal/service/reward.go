@@ -1,7 +1,6 @@
 package service
 
 import (
-	"errors"
 	"fmt"
 	"strings"
 	"time"
@@ -15,37 +14,62 @@ type GrantResult struct {
 	Granted       bool    `json:"granted"`
 	AlreadyExists bool    `json:"already_exists"`
 	RewardID      uint    `json:"reward_id,omitempty"`
+	Currency      string  `json:"currency,omitempty"`
 	Balance       float64 `json:"balance"`
 	Frozen        float64 `json:"frozen"`
 }
 
 type RewardSummary struct {
-	Pending  float64 `json:"pending"`
-	Unlocked float64 `json:"unlocked"`
-	Expired  float64 `json:"expired"`
+	Currency   string                   `json:"currency"`
+	Pending    float64                  `json:"pending"`
+	Unlocked   float64                  `json:"unlocked"`
+	Expired    float64                  `json:"expired"`
+	ByCurrency map[string]RewardSummary `json:"by_currency,omitempty"`
 }
 
 type RewardService struct {
-	db *gorm.DB
+	db      *gorm.DB
+	riskSvc *RiskService
 }
 
-func NewRewardService(db *gorm.DB) *RewardService {
-	return &RewardService{db: db}
+func NewRewardService(db *gorm.DB, riskSvc *RiskService) *RewardService {
+	return &RewardService{db: db, riskSvc: riskSvc}
 }
 
+// GrantReward pays a base-currency amount (invite, milestone, assist,
+// leaderboard config and global wheel prizes) converted into the user's
+// wallet currency.
 func (s *RewardService) GrantReward(tx *gorm.DB, userID uint, amount float64, refType, refID string, status string) (GrantResult, error) {
+	return s.grant(tx, userID, 0, amount, true, refType, refID, status)
+}
+
+func (s *RewardService) GrantCampaignReward(tx *gorm.DB, userID, campaignID uint, amount float64, refType, refID string, status string) (GrantResult, error) {
+	return s.grant(tx, userID, campaignID, amount, false, refType, refID, status)
+}
+
+func (s *RewardService) grant(tx *gorm.DB, userID, campaignID uint, amount float64, fromBase bool, refType, refID string, status string) (GrantResult, error) {
 	w := tx
 	if w == nil {
 		w = s.db
 	}
 	result := GrantResult{}
 	err := w.Transaction(func(t *gorm.DB) error {
+		currency, err := rewardCurrency(t, userID, campaignID)
+		if err != nil {
+			return err
+		}
+		if fromBase {
+			if amount, currency, err = convertFromBase(t, amount, currency); err != nil {
+				return err
+			}
+		}
 		ledger := models.WalletLedger{
-			UserID:  userID,
-			Amount:  amount,
-			Type:    "reward",
-			RefType: refType,
-			RefID:   refID,
+			UserID:   userID,
+			Amount:   amount,
+			Currency: currency,
+			Type:     "reward",
+			RefType:  refType,
+			RefID:    refID,
 		}
 		if err := t.Create(&ledger).Error; err != nil {
 			if isDuplicate(err) {
@@ -57,8 +81,10 @@ func (s *RewardService) GrantReward(tx *gorm.DB, userID uint, amount float64, re
 
 		reward := models.Reward{
 			UserID:       userID,
+			CampaignID:   campaignID,
 			Status:       status,
 			Amount:       amount,
+			Currency:     currency,
 			UnlockAmount: amount,
 			ExpireAt:     time.Now().Add(30 * 24 * time.Hour),
 			SourceType:   refType,
@@ -71,16 +97,9 @@ func (s *RewardService) GrantReward(tx *gorm.DB, userID uint, amount float64, re
 		}
 		result.RewardID = reward.ID
 
-		var wallet models.Wallet
-		if err := t.Where("user_id = ?", userID).First(&wallet).Error; err != nil {
-			if errors.Is(err, gorm.ErrRecordNotFound) {
-				wallet = models.Wallet{UserID: userID}
-				if err := t.Create(&wallet).Error; err != nil {
-					return err
-				}
-			} else {
-				return err
-			}
+		wallet, err := lockWallet(t, userID, currency)
+		if err != nil {
+			return err
 		}
 		if status == "pending" {
 			wallet.Frozen += amount
@@ -90,6 +109,7 @@ func (s *RewardService) GrantReward(tx *gorm.DB, userID uint, amount float64, re
 		if err := t.Save(&wallet).Error; err != nil {
 			return err
 		}
+		result.Currency = currency
 		result.Balance = wallet.Balance
 		result.Frozen = wallet.Frozen
 		result.Granted = true
@@ -99,33 +119,45 @@ func (s *RewardService) GrantReward(tx *gorm.DB, userID uint, amount float64, re
 }
 
 func (s *RewardService) Summary(userID uint) (RewardSummary, error) {
-	var summary RewardSummary
-	query := func(status string) (float64, error) {
-		var val float64
-		row := s.db.Model(&models.Reward{}).
-			Where("user_id = ? AND status = ?", userID, status).
-			Select("COALESCE(SUM(amount),0)").Row()
-		if err := row.Scan(&val); err != nil {
-			return 0, err
-		}
-		return val, nil
-	}
-
-	var err error
-	if summary.Pending, err = query("pending"); err != nil {
+	currency, err := userCurrency(s.db, userID)
+	if err != nil {
+		return RewardSummary{}, err
+	}
+	summary := RewardSummary{Currency: currency, ByCurrency: map[string]RewardSummary{}}
+	type row struct {
+		Currency string
+		Status   string
+		Total    float64
+	}
+	var rows []row
+	if err := s.db.Model(&models.Reward{}).
+		Select("currency, status, COALESCE(SUM(amount),0) AS total").
+		Where("user_id = ?", userID).
+		Group("currency, status").
+		Scan(&rows).Error; err != nil {
 		return summary, err
 	}
-	if summary.Unlocked, err = query("unlocked"); err != nil {
-		return summary, err
+	for _, r := range rows {
+		item := summary.ByCurrency[r.Currency]
+		item.Currency = r.Currency
+		switch r.Status {
+		case "pending":
+			item.Pending = r.Total
+		case "unlocked":
+			item.Unlocked = r.Total
+		case "expired":
+			item.Expired = r.Total
+		}
+		summary.ByCurrency[r.Currency] = item
 	}
-	if summary.Expired, err = query("expired"); err != nil {
-		return summary, err
+	if own, ok := summary.ByCurrency[currency]; ok {
+		summary.Pending, summary.Unlocked, summary.Expired = own.Pending, own.Unlocked, own.Expired
 	}
 	return summary, nil
 }
 
-func (s *RewardService) UnlockPendingRewards(userID uint) (int64, RewardSummary, error) {
-	if err := s.checkUnlockEligibility(userID); err != nil {
+func (s *RewardService) UnlockPendingRewards(userID uint, meta RequestMeta) (int64, RewardSummary, error) {
+	if _, err := s.riskSvc.Check(userID, RiskActionUnlock, meta); err != nil {
 		return 0, RewardSummary{}, err
 	}
 
@@ -139,38 +171,41 @@ func (s *RewardService) UnlockPendingRewards(userID uint) (int64, RewardSummary,
 			return nil
 		}
 
-		var total float64
+		totals := map[string]float64{}
 		now := time.Now()
 		for i := range rewards {
-			total += rewards[i].Amount
+			totals[rewards[i].Currency] += rewards[i].Amount
 			rewards[i].Status = "unlocked"
 			rewards[i].UnlockedAt = &now
 			if err := tx.Save(&rewards[i]).Error; err != nil {
 				return err
 			}
 			ledger := models.WalletLedger{
-				UserID:  userID,
-				Amount:  rewards[i].Amount,
-				Type:    "reward_unlock",
-				RefType: "reward_unlock",
-				RefID:   fmt.Sprintf("%d", rewards[i].ID),
+				UserID:   userID,
+				Amount:   rewards[i].Amount,
+				Currency: rewards[i].Currency,
+				Type:     "reward_unlock",
+				RefType:  "reward_unlock",
+				RefID:    fmt.Sprintf("%d", rewards[i].ID),
 			}
 			if err := tx.Create(&ledger).Error; err != nil && !isDuplicate(err) {
 				return err
 			}
 		}
 
-		var wallet models.Wallet
-		if err := tx.Where("user_id = ?", userID).First(&wallet).Error; err != nil {
-			return err
-		}
-		wallet.Frozen -= total
-		if wallet.Frozen < 0 {
-			wallet.Frozen = 0
-		}
-		wallet.Balance += total
-		if err := tx.Save(&wallet).Error; err != nil {
-			return err
+		for currency, total := range totals {
+			wallet, err := lockWallet(tx, userID, currency)
+			if err != nil {
+				return err
+			}
+			wallet.Frozen -= total
+			if wallet.Frozen < 0 {
+				wallet.Frozen = 0
+			}
+			wallet.Balance += total
+			if err := tx.Save(&wallet).Error; err != nil {
+				return err
+			}
 		}
 		unlockedCount = int64(len(rewards))
 		return nil
@@ -182,30 +217,6 @@ func (s *RewardService) UnlockPendingRewards(userID uint) (int64, RewardSummary,
 	return unlockedCount, summary, err
 }
 
-func (s *RewardService) checkUnlockEligibility(userID uint) error {
-	var user models.User
-	if err := s.db.First(&user, userID).Error; err != nil {
-		return err
-	}
-	if user.DeviceHash != "" {
-		var dupCount int64
-		if err := s.db.Model(&models.User{}).Where("device_hash = ?", user.DeviceHash).Count(&dupCount).Error; err != nil {
-			return err
-		}
-		if dupCount > 3 {
-			return ErrRiskCheckFailed
-		}
-	}
-	var riskScore int64
-	if err := s.db.Model(&models.RiskFlag{}).Where("user_id = ?", userID).Select("COALESCE(SUM(score),0)").Scan(&riskScore).Error; err != nil {
-		return err
-	}
-	if riskScore >= 100 {
-		return ErrRiskCheckFailed
-	}
-	return nil
-}
-
 func (s *RewardService) ListByUser(userID uint, status string, page, size int) ([]models.Reward, error) {
 	if page < 1 {
 		page = 1
@@ -1,47 +1,96 @@
 package service
 
 import (
+	"time"
+
 	"gorm.io/gorm"
 
 	"red_packet/backend/internal/models"
 )
 
 type RiskService struct {
-	db *gorm.DB
+	db     *gorm.DB
+	engine *RiskEngine
 }
 
 func NewRiskService(db *gorm.DB) *RiskService {
-	return &RiskService{db: db}
+	return &RiskService{db: db, engine: NewRiskEngine(db)}
+}
+
+func (s *RiskService) Engine() *RiskEngine {
+	return s.engine
 }
 
-func (s *RiskService) CheckWithdrawEligibility(userID uint) error {
+func (s *RiskService) Evaluate(userID uint, action string, meta RequestMeta) (RiskDecisionResult, error) {
 	var user models.User
 	if err := s.db.First(&user, userID).Error; err != nil {
-		return err
+		return RiskDecisionResult{}, err
 	}
+	return s.engine.Evaluate(RiskInput{UserID: userID, Action: action, Meta: meta, User: user})
+}
 
-	if user.DeviceHash != "" {
-		var dupCount int64
-		if err := s.db.Model(&models.User{}).Where("device_hash = ?", user.DeviceHash).Count(&dupCount).Error; err != nil {
-			return err
-		}
-		if dupCount > 3 {
-			return ErrRiskCheckFailed
-		}
+func (s *RiskService) Check(userID uint, action string, meta RequestMeta) (RiskDecisionResult, error) {
+	decision, err := s.Evaluate(userID, action, meta)
+	if err != nil {
+		return decision, err
 	}
+	if decision.Decision == RiskDeny {
+		return decision, ErrRiskCheckFailed
+	}
+	return decision, nil
+}
 
-	var riskScore int64
-	if err := s.db.Model(&models.RiskFlag{}).Where("user_id = ?", userID).Select("COALESCE(SUM(score),0)").Scan(&riskScore).Error; err != nil {
-		return err
+// CheckRegistration evaluates an account that has not been created yet, so a
+// denied device, IP, phone or email never gets a user row.
+func (s *RiskService) CheckRegistration(candidate models.User, meta RequestMeta) (RiskDecisionResult, error) {
+	decision, err := s.engine.Evaluate(RiskInput{Action: RiskActionRegister, Meta: meta, User: candidate})
+	if err != nil {
+		return decision, err
 	}
-	if riskScore >= 100 {
-		return ErrRiskCheckFailed
+	if decision.Decision == RiskDeny {
+		return decision, ErrRiskCheckFailed
+	}
+	return decision, nil
+}
+
+func (s *RiskService) CheckWithdrawEligibility(userID uint, meta RequestMeta) (RiskDecisionResult, error) {
+	decision, err := s.Check(userID, RiskActionWithdraw, meta)
+	if err != nil {
+		return decision, err
 	}
 
-	return nil
+	var user models.User
+	if err := s.db.First(&user, userID).Error; err != nil {
+		return decision, err
+	}
+	if loadKYCRequirement(s.db, user.Country).Required && kycStatusOf(user) != "verified" {
+		return decision, ErrKYCRequired
+	}
+	return decision, nil
 }
 
-func (s *RiskService) ListFlags(userID uint, page, size int) ([]models.RiskFlag, error) {
+func (s *RiskService) ListDecisions(userID uint, action string, page, size int) ([]models.RiskDecision, error) {
+	if page < 1 {
+		page = 1
+	}
+	if size < 1 || size > 100 {
+		size = 20
+	}
+	q := s.db.Model(&models.RiskDecision{})
+	if userID > 0 {
+		q = q.Where("user_id = ?", userID)
+	}
+	if action != "" {
+		q = q.Where("action = ?", action)
+	}
+	var items []models.RiskDecision
+	if err := q.Order("id DESC").Offset((page - 1) * size).Limit(size).Find(&items).Error; err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func (s *RiskService) ListFlags(userID uint, status string, page, size int) ([]models.RiskFlag, error) {
 	if page < 1 {
 		page = 1
 	}
@@ -52,6 +101,9 @@ func (s *RiskService) ListFlags(userID uint, page, size int) ([]models.RiskFlag,
 	if userID > 0 {
 		q = q.Where("user_id = ?", userID)
 	}
+	if status != "" {
+		q = q.Where("status = ?", status)
+	}
 	var items []models.RiskFlag
 	if err := q.Order("id DESC").Offset((page - 1) * size).Limit(size).Find(&items).Error; err != nil {
 		return nil, err
@@ -59,8 +111,15 @@ func (s *RiskService) ListFlags(userID uint, page, size int) ([]models.RiskFlag,
 	return items, nil
 }
 
-func (s *RiskService) AddFlag(userID uint, reason string, score int) (models.RiskFlag, error) {
-	item := models.RiskFlag{UserID: userID, Reason: reason, Score: score}
+func (s *RiskService) AddFlag(userID uint, reason string, score int, expireHours float64) (models.RiskFlag, error) {
+	item := models.RiskFlag{
+		UserID:   userID,
+		Reason:   reason,
+		Score:    score,
+		Source:   "manual",
+		Status:   "active",
+		ExpireAt: s.flagExpireAt(expireHours, time.Now()),
+	}
 	if err := s.db.Create(&item).Error; err != nil {
 		return models.RiskFlag{}, err
 	}
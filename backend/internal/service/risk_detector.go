@@ -0,0 +1,250 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+
+	"red_packet/backend/internal/models"
+)
+
+type RiskDetectorConfig struct {
+	Enabled       bool `json:"enabled"`
+	Threshold     int  `json:"threshold"`
+	WindowSeconds int  `json:"window_seconds"`
+	Score         int  `json:"score"`
+}
+
+var defaultRiskDetectors = map[string]RiskDetectorConfig{
+	"invitee_shared_env":    {Enabled: true, Threshold: 1, Score: 30},
+	"spin_speed":            {Enabled: true, Threshold: 5, WindowSeconds: 10, Score: 30},
+	"claim_burst":           {Enabled: true, Threshold: 10, WindowSeconds: 60, Score: 30},
+	"withdraw_after_unlock": {Enabled: true, Threshold: 1, WindowSeconds: 300, Score: 20},
+	"referral_burst":        {Enabled: true, Threshold: 10, WindowSeconds: 600, Score: 40},
+	"device_cluster":        {Enabled: true, Threshold: 5, WindowSeconds: 86400, Score: 40},
+}
+
+func (s *RiskService) DetectorConfigs() map[string]RiskDetectorConfig {
+	out := make(map[string]RiskDetectorConfig, len(defaultRiskDetectors))
+	for k, v := range defaultRiskDetectors {
+		out[k] = v
+	}
+	var custom map[string]json.RawMessage
+	if err := jsonUnmarshal(loadConfigString(s.db, "risk_detectors", ""), &custom); err == nil {
+		for k, raw := range custom {
+			cfg := out[k]
+			if err := json.Unmarshal(raw, &cfg); err == nil {
+				out[k] = cfg
+			}
+		}
+	}
+	return out
+}
+
+func (s *RiskService) detector(name string) (RiskDetectorConfig, bool) {
+	cfg, ok := s.DetectorConfigs()[name]
+	return cfg, ok && cfg.Enabled
+}
+
+func (s *RiskService) addAutoFlag(userID uint, detector, reason string, score int, dedupKey string) (bool, error) {
+	key := detector + ":" + dedupKey
+	item := models.RiskFlag{
+		UserID:   userID,
+		Reason:   reason,
+		Score:    score,
+		Source:   "auto",
+		Detector: detector,
+		DedupKey: &key,
+		Status:   "active",
+		ExpireAt: s.flagExpireAt(loadConfigFloat(s.db, "risk_flag_auto_ttl_hours", 0), time.Now()),
+	}
+	if err := s.db.Create(&item).Error; err != nil {
+		if isDuplicate(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+func detectorWindow(cfg RiskDetectorConfig, now time.Time) time.Time {
+	return now.Add(-time.Duration(cfg.WindowSeconds) * time.Second)
+}
+
+func detectorBucket(cfg RiskDetectorConfig, now time.Time) int64 {
+	if cfg.WindowSeconds <= 0 {
+		return 0
+	}
+	return now.Unix() / int64(cfg.WindowSeconds)
+}
+
+func (s *RiskService) DetectBind(childID, parentID uint, meta RequestMeta) {
+	cfg, ok := s.detector("invitee_shared_env")
+	if !ok {
+		return
+	}
+	var users []models.User
+	if err := s.db.Where("id IN ?", []uint{childID, parentID}).Find(&users).Error; err != nil {
+		log.Printf("risk detector invitee_shared_env: %v", err)
+		return
+	}
+	devices := map[uint]string{}
+	for _, u := range users {
+		devices[u.ID] = u.DeviceHash
+	}
+	childDevice := meta.DeviceHash
+	if childDevice == "" {
+		childDevice = devices[childID]
+	}
+	if childDevice != "" && childDevice == devices[parentID] {
+		if _, err := s.addAutoFlag(childID, "invitee_shared_env", fmt.Sprintf("invitee shares device with inviter %d", parentID), cfg.Score, fmt.Sprintf("device:%d", childID)); err != nil {
+			log.Printf("risk detector invitee_shared_env: %v", err)
+		}
+	}
+	if meta.IP == "" {
+		return
+	}
+	var parentDecision models.RiskDecision
+	if err := s.db.Where("user_id = ? AND ip <> ''", parentID).Order("id DESC").First(&parentDecision).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("risk detector invitee_shared_env: %v", err)
+		}
+		return
+	}
+	if parentDecision.IP == meta.IP {
+		if _, err := s.addAutoFlag(childID, "invitee_shared_env", fmt.Sprintf("invitee shares ip with inviter %d", parentID), cfg.Score, fmt.Sprintf("ip:%d", childID)); err != nil {
+			log.Printf("risk detector invitee_shared_env: %v", err)
+		}
+	}
+}
+
+func (s *RiskService) DetectSpin(userID uint) {
+	cfg, ok := s.detector("spin_speed")
+	if !ok {
+		return
+	}
+	now := time.Now()
+	var count int64
+	if err := s.db.Model(&models.SpinRecord{}).
+		Where("user_id = ? AND created_at >= ?", userID, detectorWindow(cfg, now)).
+		Count(&count).Error; err != nil {
+		log.Printf("risk detector spin_speed: %v", err)
+		return
+	}
+	if count < int64(cfg.Threshold) {
+		return
+	}
+	reason := fmt.Sprintf("%d spins within %ds", count, cfg.WindowSeconds)
+	if _, err := s.addAutoFlag(userID, "spin_speed", reason, cfg.Score, fmt.Sprintf("%d:%d", userID, detectorBucket(cfg, now))); err != nil {
+		log.Printf("risk detector spin_speed: %v", err)
+	}
+}
+
+func (s *RiskService) DetectClaim(userID uint) {
+	cfg, ok := s.detector("claim_burst")
+	if !ok {
+		return
+	}
+	now := time.Now()
+	var count int64
+	if err := s.db.Model(&models.UserTaskEvent{}).
+		Where("user_id = ? AND created_at >= ?", userID, detectorWindow(cfg, now)).
+		Count(&count).Error; err != nil {
+		log.Printf("risk detector claim_burst: %v", err)
+		return
+	}
+	if count < int64(cfg.Threshold) {
+		return
+	}
+	reason := fmt.Sprintf("%d task claims within %ds", count, cfg.WindowSeconds)
+	if _, err := s.addAutoFlag(userID, "claim_burst", reason, cfg.Score, fmt.Sprintf("%d:%d", userID, detectorBucket(cfg, now))); err != nil {
+		log.Printf("risk detector claim_burst: %v", err)
+	}
+}
+
+func (s *RiskService) DetectWithdraw(userID, requestID uint) {
+	cfg, ok := s.detector("withdraw_after_unlock")
+	if !ok {
+		return
+	}
+	var count int64
+	if err := s.db.Model(&models.WalletLedger{}).
+		Where("user_id = ? AND type = ? AND created_at >= ?", userID, "reward_unlock", detectorWindow(cfg, time.Now())).
+		Count(&count).Error; err != nil {
+		log.Printf("risk detector withdraw_after_unlock: %v", err)
+		return
+	}
+	if count < int64(cfg.Threshold) {
+		return
+	}
+	reason := fmt.Sprintf("withdraw #%d within %ds of reward unlock", requestID, cfg.WindowSeconds)
+	if _, err := s.addAutoFlag(userID, "withdraw_after_unlock", reason, cfg.Score, fmt.Sprintf("%d", requestID)); err != nil {
+		log.Printf("risk detector withdraw_after_unlock: %v", err)
+	}
+}
+
+func (s *RiskService) RunDetectors(now time.Time) (int, error) {
+	total := 0
+	if cfg, ok := s.detector("referral_burst"); ok {
+		type row struct {
+			ParentUserID uint
+			Cnt          int
+		}
+		var rows []row
+		if err := s.db.Model(&models.ReferralEdge{}).
+			Select("parent_user_id, COUNT(*) AS cnt").
+			Where("level = 1 AND created_at >= ?", detectorWindow(cfg, now)).
+			Group("parent_user_id").
+			Having("COUNT(*) >= ?", cfg.Threshold).
+			Scan(&rows).Error; err != nil {
+			return total, err
+		}
+		for _, r := range rows {
+			reason := fmt.Sprintf("%d invitees bound within %ds", r.Cnt, cfg.WindowSeconds)
+			created, err := s.addAutoFlag(r.ParentUserID, "referral_burst", reason, cfg.Score, fmt.Sprintf("%d:%d", r.ParentUserID, detectorBucket(cfg, now)))
+			if err != nil {
+				return total, err
+			}
+			if created {
+				total++
+			}
+		}
+	}
+
+	if cfg, ok := s.detector("device_cluster"); ok {
+		type row struct {
+			DeviceHash string
+			Cnt        int
+		}
+		var rows []row
+		if err := s.db.Model(&models.User{}).
+			Select("device_hash, COUNT(*) AS cnt").
+			Where("device_hash <> '' AND created_at >= ?", detectorWindow(cfg, now)).
+			Group("device_hash").
+			Having("COUNT(*) >= ?", cfg.Threshold).
+			Scan(&rows).Error; err != nil {
+			return total, err
+		}
+		for _, r := range rows {
+			var userIDs []uint
+			if err := s.db.Model(&models.User{}).Where("device_hash = ?", r.DeviceHash).Pluck("id", &userIDs).Error; err != nil {
+				return total, err
+			}
+			for _, uid := range userIDs {
+				reason := fmt.Sprintf("device shared by %d accounts", r.Cnt)
+				created, err := s.addAutoFlag(uid, "device_cluster", reason, cfg.Score, fmt.Sprintf("%d", uid))
+				if err != nil {
+					return total, err
+				}
+				if created {
+					total++
+				}
+			}
+		}
+	}
+	return total, nil
+}
@@ -0,0 +1,368 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"red_packet/backend/internal/models"
+)
+
+const (
+	RiskActionLogin    = "login"
+	RiskActionRegister = "register"
+	RiskActionBind     = "bind"
+	RiskActionClaim    = "claim"
+	RiskActionSpin     = "spin"
+	RiskActionUnlock   = "unlock"
+	RiskActionWithdraw = "withdraw"
+
+	RiskActionInviteValid = "invite_valid"
+	RiskActionAssist      = "assist"
+
+	RiskAllow  = "allow"
+	RiskReview = "review"
+	RiskDeny   = "deny"
+)
+
+type RequestMeta struct {
+	IP         string `json:"ip"`
+	DeviceHash string `json:"device_hash"`
+	UserAgent  string `json:"user_agent"`
+}
+
+type RiskInput struct {
+	UserID uint
+	Action string
+	Meta   RequestMeta
+	User   models.User
+	Now    time.Time
+}
+
+type RiskRuleConfig struct {
+	Enabled       bool     `json:"enabled"`
+	Actions       []string `json:"actions"`
+	Threshold     float64  `json:"threshold"`
+	WindowMinutes int      `json:"window_minutes"`
+	Score         int      `json:"score"`
+	Decision      string   `json:"decision,omitempty"`
+}
+
+type RiskThreshold struct {
+	Review int `json:"review"`
+	Deny   int `json:"deny"`
+}
+
+type RiskRuleHit struct {
+	Rule     string `json:"rule"`
+	Score    int    `json:"score"`
+	Reason   string `json:"reason"`
+	Decision string `json:"decision,omitempty"`
+}
+
+type RiskDecisionResult struct {
+	ID        uint          `json:"id"`
+	Action    string        `json:"action"`
+	Decision  string        `json:"decision"`
+	Score     int           `json:"score"`
+	Breakdown []RiskRuleHit `json:"breakdown"`
+}
+
+type RiskRuleView struct {
+	Name   string         `json:"name"`
+	Config RiskRuleConfig `json:"config"`
+}
+
+type RiskRule interface {
+	Name() string
+	Evaluate(db *gorm.DB, in RiskInput, cfg RiskRuleConfig) (int, string, error)
+}
+
+type RiskEngine struct {
+	db    *gorm.DB
+	rules map[string]RiskRule
+	order []string
+}
+
+var defaultRiskRules = map[string]RiskRuleConfig{
+	"blacklist":      {Enabled: true, Actions: []string{"*"}, Score: 100, Decision: RiskDeny},
+	"device_sharing": {Enabled: true, Actions: []string{RiskActionUnlock, RiskActionWithdraw, RiskActionInviteValid}, Threshold: 3, Score: 100},
+	"risk_flags":     {Enabled: true, Actions: []string{RiskActionUnlock, RiskActionWithdraw, RiskActionInviteValid}},
+	"ip_sharing":     {Enabled: true, Actions: []string{RiskActionBind, RiskActionWithdraw}, Threshold: 5, WindowMinutes: 1440, Score: 40},
+	"velocity":       {Enabled: true, Actions: []string{RiskActionSpin, RiskActionClaim, RiskActionBind, RiskActionAssist}, Threshold: 60, WindowMinutes: 1, Score: 60},
+	"referral_tree":  {Enabled: true, Actions: []string{RiskActionUnlock, RiskActionWithdraw}, Threshold: 20, WindowMinutes: 60, Score: 50},
+}
+
+var defaultRiskThresholds = map[string]RiskThreshold{
+	"*": {Review: 60, Deny: 100},
+}
+
+func NewRiskEngine(db *gorm.DB) *RiskEngine {
+	e := &RiskEngine{db: db, rules: map[string]RiskRule{}}
+	e.Register(blacklistRule{})
+	e.Register(deviceSharingRule{})
+	e.Register(riskFlagRule{})
+	e.Register(ipSharingRule{})
+	e.Register(velocityRule{})
+	e.Register(referralTreeRule{})
+	return e
+}
+
+func (e *RiskEngine) Register(rule RiskRule) {
+	if _, exists := e.rules[rule.Name()]; !exists {
+		e.order = append(e.order, rule.Name())
+	}
+	e.rules[rule.Name()] = rule
+}
+
+func (e *RiskEngine) Rules() []RiskRuleView {
+	configs := e.loadRuleConfigs()
+	out := make([]RiskRuleView, 0, len(e.order))
+	for _, name := range e.order {
+		out = append(out, RiskRuleView{Name: name, Config: configs[name]})
+	}
+	return out
+}
+
+func (e *RiskEngine) Evaluate(in RiskInput) (RiskDecisionResult, error) {
+	if in.Now.IsZero() {
+		in.Now = time.Now()
+	}
+	if in.Meta.DeviceHash == "" {
+		in.Meta.DeviceHash = in.User.DeviceHash
+	}
+	configs := e.loadRuleConfigs()
+	threshold := e.loadThreshold(in.Action)
+
+	result := RiskDecisionResult{Action: in.Action, Decision: RiskAllow, Breakdown: []RiskRuleHit{}}
+	forced := ""
+	for _, name := range e.order {
+		cfg, ok := configs[name]
+		if !ok || !cfg.Enabled || !riskActionMatch(cfg.Actions, in.Action) {
+			continue
+		}
+		score, reason, err := e.rules[name].Evaluate(e.db, in, cfg)
+		if err != nil {
+			return result, err
+		}
+		if score <= 0 && reason == "" {
+			continue
+		}
+		hit := RiskRuleHit{Rule: name, Score: score, Reason: reason}
+		if cfg.Decision == RiskDeny || (cfg.Decision == RiskReview && forced != RiskDeny) {
+			hit.Decision = cfg.Decision
+			forced = cfg.Decision
+		}
+		result.Score += score
+		result.Breakdown = append(result.Breakdown, hit)
+	}
+
+	switch {
+	case forced == RiskDeny || (threshold.Deny > 0 && result.Score >= threshold.Deny):
+		result.Decision = RiskDeny
+	case forced == RiskReview || (threshold.Review > 0 && result.Score >= threshold.Review):
+		result.Decision = RiskReview
+	}
+
+	breakdown, _ := json.Marshal(result.Breakdown)
+	row := models.RiskDecision{
+		UserID:     in.UserID,
+		Action:     in.Action,
+		Decision:   result.Decision,
+		Score:      result.Score,
+		Breakdown:  string(breakdown),
+		IP:         in.Meta.IP,
+		DeviceHash: in.Meta.DeviceHash,
+	}
+	if err := e.db.Create(&row).Error; err != nil {
+		return result, err
+	}
+	result.ID = row.ID
+	return result, nil
+}
+
+func (e *RiskEngine) loadRuleConfigs() map[string]RiskRuleConfig {
+	out := make(map[string]RiskRuleConfig, len(defaultRiskRules))
+	for k, v := range defaultRiskRules {
+		out[k] = v
+	}
+	var custom map[string]json.RawMessage
+	if err := jsonUnmarshal(loadConfigString(e.db, "risk_rules", ""), &custom); err == nil {
+		for k, raw := range custom {
+			// Overrides are partial: decode over a copy of the default.
+			cfg := out[k]
+			cfg.Actions = append([]string(nil), cfg.Actions...)
+			if err := json.Unmarshal(raw, &cfg); err == nil {
+				out[k] = cfg
+			}
+		}
+	}
+	return out
+}
+
+func (e *RiskEngine) loadThreshold(action string) RiskThreshold {
+	thresholds := defaultRiskThresholds
+	var custom map[string]RiskThreshold
+	if err := jsonUnmarshal(loadConfigString(e.db, "risk_thresholds", ""), &custom); err == nil && len(custom) > 0 {
+		thresholds = custom
+	}
+	if t, ok := thresholds[action]; ok {
+		return t
+	}
+	return thresholds["*"]
+}
+
+func riskActionMatch(actions []string, action string) bool {
+	for _, a := range actions {
+		if a == "*" || a == action {
+			return true
+		}
+	}
+	return false
+}
+
+func riskWindowStart(in RiskInput, cfg RiskRuleConfig) time.Time {
+	minutes := cfg.WindowMinutes
+	if minutes <= 0 {
+		minutes = 60
+	}
+	return in.Now.Add(-time.Duration(minutes) * time.Minute)
+}
+
+type blacklistRule struct{}
+
+func (blacklistRule) Name() string { return "blacklist" }
+
+func (blacklistRule) Evaluate(db *gorm.DB, in RiskInput, cfg RiskRuleConfig) (int, string, error) {
+	values := map[string]string{
+		"user_id":     fmt.Sprintf("%d", in.UserID),
+		"ip":          in.Meta.IP,
+		"device_hash": in.Meta.DeviceHash,
+		"phone":       derefString(in.User.Phone),
+		"email":       derefString(in.User.Email),
+	}
+	q := db.Model(&models.Blacklist{}).Where("1 = 0")
+	for typ, value := range values {
+		if value == "" || value == "0" {
+			continue
+		}
+		q = q.Or("type = ? AND value = ?", typ, value)
+	}
+	var hits []models.Blacklist
+	if err := q.Limit(10).Find(&hits).Error; err != nil {
+		return 0, "", err
+	}
+	if len(hits) == 0 {
+		return 0, "", nil
+	}
+	types := make([]string, 0, len(hits))
+	for _, h := range hits {
+		types = append(types, h.Type)
+	}
+	sort.Strings(types)
+	return cfg.Score, "blacklist hit: " + strings.Join(types, ","), nil
+}
+
+type deviceSharingRule struct{}
+
+func (deviceSharingRule) Name() string { return "device_sharing" }
+
+func (deviceSharingRule) Evaluate(db *gorm.DB, in RiskInput, cfg RiskRuleConfig) (int, string, error) {
+	// The stored registration hash is authoritative; the request header is
+	// client supplied and only ever adds to the count.
+	hashes := []string{in.User.DeviceHash}
+	if in.Meta.DeviceHash != in.User.DeviceHash {
+		hashes = append(hashes, in.Meta.DeviceHash)
+	}
+	var count int64
+	for _, hash := range hashes {
+		if hash == "" {
+			continue
+		}
+		var n int64
+		if err := db.Model(&models.User{}).Where("device_hash = ?", hash).Count(&n).Error; err != nil {
+			return 0, "", err
+		}
+		if n > count {
+			count = n
+		}
+	}
+	if count == 0 || float64(count) <= cfg.Threshold {
+		return 0, "", nil
+	}
+	return cfg.Score, fmt.Sprintf("%d users share device", count), nil
+}
+
+type riskFlagRule struct{}
+
+func (riskFlagRule) Name() string { return "risk_flags" }
+
+func (riskFlagRule) Evaluate(db *gorm.DB, in RiskInput, _ RiskRuleConfig) (int, string, error) {
+	score, err := activeRiskFlagScore(db, in.UserID, in.Now)
+	if err != nil {
+		return 0, "", err
+	}
+	if score <= 0 {
+		return 0, "", nil
+	}
+	return score, "active risk flag score", nil
+}
+
+type ipSharingRule struct{}
+
+func (ipSharingRule) Name() string { return "ip_sharing" }
+
+func (ipSharingRule) Evaluate(db *gorm.DB, in RiskInput, cfg RiskRuleConfig) (int, string, error) {
+	if in.Meta.IP == "" {
+		return 0, "", nil
+	}
+	var count int64
+	if err := db.Model(&models.RiskDecision{}).
+		Where("ip = ? AND user_id <> ? AND created_at >= ?", in.Meta.IP, in.UserID, riskWindowStart(in, cfg)).
+		Distinct("user_id").
+		Count(&count).Error; err != nil {
+		return 0, "", err
+	}
+	if float64(count) < cfg.Threshold {
+		return 0, "", nil
+	}
+	return cfg.Score, fmt.Sprintf("%d other users on ip", count), nil
+}
+
+type velocityRule struct{}
+
+func (velocityRule) Name() string { return "velocity" }
+
+func (velocityRule) Evaluate(db *gorm.DB, in RiskInput, cfg RiskRuleConfig) (int, string, error) {
+	var count int64
+	if err := db.Model(&models.RiskDecision{}).
+		Where("user_id = ? AND action = ? AND created_at >= ?", in.UserID, in.Action, riskWindowStart(in, cfg)).
+		Count(&count).Error; err != nil {
+		return 0, "", err
+	}
+	if float64(count) < cfg.Threshold {
+		return 0, "", nil
+	}
+	return cfg.Score, fmt.Sprintf("%d %s actions in window", count, in.Action), nil
+}
+
+type referralTreeRule struct{}
+
+func (referralTreeRule) Name() string { return "referral_tree" }
+
+func (referralTreeRule) Evaluate(db *gorm.DB, in RiskInput, cfg RiskRuleConfig) (int, string, error) {
+	var count int64
+	if err := db.Model(&models.ReferralEdge{}).
+		Where("parent_user_id = ? AND level = 1 AND created_at >= ?", in.UserID, riskWindowStart(in, cfg)).
+		Count(&count).Error; err != nil {
+		return 0, "", err
+	}
+	if float64(count) < cfg.Threshold {
+		return 0, "", nil
+	}
+	return cfg.Score, fmt.Sprintf("%d invitees bound in window", count), nil
+}
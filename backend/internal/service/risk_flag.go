@@ -0,0 +1,121 @@
+package service
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"gorm.io/gorm"
+
+	"red_packet/backend/internal/models"
+)
+
+// openRiskFlagStatuses are the statuses the expiry job still moves to expired;
+// only "active" flags count towards the risk score.
+var openRiskFlagStatuses = []string{"active", "appealed"}
+
+func (s *RiskService) ActiveFlagScore(userID uint) (int, error) {
+	return activeRiskFlagScore(s.db, userID, time.Now())
+}
+
+func activeRiskFlagScore(db *gorm.DB, userID uint, now time.Time) (int, error) {
+	var flags []models.RiskFlag
+	if err := db.Where("user_id = ? AND status = ? AND (expire_at IS NULL OR expire_at > ?)", userID, "active", now).
+		Find(&flags).Error; err != nil {
+		return 0, err
+	}
+	halfLife := loadConfigFloat(db, "risk_flag_half_life_hours", 720)
+	total := 0.0
+	for _, f := range flags {
+		total += decayedFlagScore(f, halfLife, now)
+	}
+	return int(math.Round(total)), nil
+}
+
+// decayedFlagScore halves a flag's score every half-life, manual or auto. A
+// ban that must keep denying belongs on the blacklist, not in a flag.
+func decayedFlagScore(f models.RiskFlag, halfLifeHours float64, now time.Time) float64 {
+	if halfLifeHours <= 0 {
+		return float64(f.Score)
+	}
+	age := now.Sub(f.CreatedAt).Hours()
+	if age <= 0 {
+		return float64(f.Score)
+	}
+	return float64(f.Score) * math.Pow(0.5, age/halfLifeHours)
+}
+
+func (s *RiskService) flagExpireAt(ttlHours float64, now time.Time) *time.Time {
+	if ttlHours <= 0 {
+		ttlHours = loadConfigFloat(s.db, "risk_flag_default_ttl_hours", 0)
+	}
+	if ttlHours <= 0 {
+		return nil
+	}
+	at := now.Add(time.Duration(ttlHours * float64(time.Hour)))
+	return &at
+}
+
+func (s *RiskService) ResolveFlag(flagID uint, note string) (models.RiskFlag, error) {
+	return s.transitionFlag(flagID, "resolved", note)
+}
+
+func (s *RiskService) AppealFlag(flagID uint, note string) (models.RiskFlag, error) {
+	return s.transitionFlag(flagID, "appealed", note)
+}
+
+func (s *RiskService) ReinstateFlag(flagID uint, note string) (models.RiskFlag, error) {
+	return s.transitionFlag(flagID, "active", note)
+}
+
+func (s *RiskService) transitionFlag(flagID uint, status, note string) (models.RiskFlag, error) {
+	var item models.RiskFlag
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&item, flagID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrRiskFlagNotFound
+			}
+			return err
+		}
+		if !validRiskFlagTransition(item.Status, status) {
+			return ErrRiskFlagState
+		}
+		updates := map[string]interface{}{"status": status, "resolver_note": note}
+		if status == "resolved" {
+			now := time.Now()
+			updates["resolved_at"] = &now
+		}
+		res := tx.Model(&models.RiskFlag{}).Where("id = ? AND status = ?", item.ID, item.Status).Updates(updates)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrRiskFlagState
+		}
+		return tx.First(&item, flagID).Error
+	})
+	if err != nil {
+		return models.RiskFlag{}, err
+	}
+	return item, nil
+}
+
+func validRiskFlagTransition(from, to string) bool {
+	if from == "" {
+		from = "active"
+	}
+	switch from {
+	case "active":
+		return to == "appealed" || to == "resolved"
+	case "appealed":
+		return to == "active" || to == "resolved"
+	}
+	return false
+}
+
+func (s *RiskService) ExpireFlags(now time.Time) (int, error) {
+	res := s.db.Model(&models.RiskFlag{}).
+		Where("status IN ? AND expire_at IS NOT NULL AND expire_at <= ?", openRiskFlagStatuses, now).
+		Update("status", "expired")
+	return int(res.RowsAffected), res.Error
+}
@@ -0,0 +1,29 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"red_packet/backend/internal/models"
+)
+
+func TestDecayedFlagScore(t *testing.T) {
+	now := time.Now()
+	created := now.Add(-720 * time.Hour)
+	cases := []struct {
+		name     string
+		source   string
+		halfLife float64
+		want     float64
+	}{
+		{"manual flag halves after one half-life", "manual", 720, 50},
+		{"auto flag halves after one half-life", "auto", 720, 50},
+		{"decay disabled", "auto", 0, 100},
+	}
+	for _, c := range cases {
+		f := models.RiskFlag{Score: 100, Source: c.source, CreatedAt: created}
+		if got := decayedFlagScore(f, c.halfLife, now); got != c.want {
+			t.Errorf("%s: score = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
@@ -2,8 +2,10 @@ package service
 
 import (
 	"errors"
+	"fmt"
 	"math"
 	"strings"
+	"time"
 
 	"gorm.io/gorm"
 
@@ -20,30 +22,62 @@ type TaskService struct {
 	db          *gorm.DB
 	lotterySvc  *LotteryService
 	referralSvc *ReferralService
+	riskSvc     *RiskService
+	geoIP       *GeoIP
+	registry    *TaskRegistry
+
+	callbackSecrets map[string]string
+}
+
+type TaskCopy struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
 }
 
 type TaskView struct {
-	ID                uint    `json:"id"`
-	Type              string  `json:"type"`
-	Name              string  `json:"name"`
-	RewardAmount      float64 `json:"reward_amount"`
-	Enabled           bool    `json:"enabled"`
-	CountryScope      string  `json:"country_scope"`
-	Claimed           bool    `json:"claimed"`
-	LastClaimEventKey string  `json:"last_claim_event_key,omitempty"`
+	ID                uint             `json:"id"`
+	CampaignID        uint             `json:"campaign_id"`
+	Type              string           `json:"type"`
+	Name              string           `json:"name"`
+	Description       string           `json:"description"`
+	RewardAmount      float64          `json:"reward_amount"`
+	Enabled           bool             `json:"enabled"`
+	CountryScope      string           `json:"country_scope"`
+	SortOrder         int              `json:"sort_order"`
+	Verification      string           `json:"verification"`
+	Prerequisites     []uint           `json:"prerequisites"`
+	State             string           `json:"state"`
+	LockReasons       []TaskLockReason `json:"lock_reasons,omitempty"`
+	Claimed           bool             `json:"claimed"`
+	LastClaimEventKey string           `json:"last_claim_event_key,omitempty"`
 }
 
-func NewTaskService(db *gorm.DB, lotterySvc *LotteryService, referralSvc *ReferralService) *TaskService {
-	return &TaskService{db: db, lotterySvc: lotterySvc, referralSvc: referralSvc}
+func NewTaskService(db *gorm.DB, lotterySvc *LotteryService, referralSvc *ReferralService, riskSvc *RiskService, geoIP *GeoIP, callbackSecrets map[string]string) *TaskService {
+	return &TaskService{
+		db:              db,
+		lotterySvc:      lotterySvc,
+		referralSvc:     referralSvc,
+		riskSvc:         riskSvc,
+		geoIP:           geoIP,
+		registry:        NewTaskRegistry(),
+		callbackSecrets: callbackSecrets,
+	}
 }
 
-func (s *TaskService) Claim(userID uint, in ClaimInput) (int, error) {
+func (s *TaskService) Claim(userID uint, in ClaimInput, meta RequestMeta) (int, error) {
 	if in.EventKey == "" {
 		return 0, ErrAlreadyClaimed
 	}
+	if _, err := s.riskSvc.Check(userID, RiskActionClaim, meta); err != nil {
+		return 0, err
+	}
+	_, country, err := s.userLocale(userID, meta)
+	if err != nil {
+		return 0, err
+	}
 
 	var spinCount int
-	err := s.db.Transaction(func(tx *gorm.DB) error {
+	err = s.db.Transaction(func(tx *gorm.DB) error {
 		var task models.Task
 		if err := tx.Where("id = ? AND enabled = ?", in.TaskID, true).First(&task).Error; err != nil {
 			if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -51,16 +85,36 @@ func (s *TaskService) Claim(userID uint, in ClaimInput) (int, error) {
 			}
 			return err
 		}
+		if !countryInScope(task.CountryScope, country) {
+			return ErrTaskOutOfScope
+		}
+		if _, err := loadActiveCampaign(tx, task.CampaignID, country, time.Now()); err != nil {
+			return err
+		}
 
+		now := time.Now()
+		periodStart := s.claimPeriodStart(task.Type, now)
+		existedQ := tx.Model(&models.UserTaskEvent{}).Where("user_id = ? AND task_id = ?", userID, in.TaskID)
+		if !periodStart.IsZero() {
+			existedQ = existedQ.Where("created_at >= ?", periodStart)
+		}
 		var existed int64
-		if err := tx.Model(&models.UserTaskEvent{}).
-			Where("user_id = ? AND task_id = ?", userID, in.TaskID).
-			Count(&existed).Error; err != nil {
+		if err := existedQ.Count(&existed).Error; err != nil {
 			return err
 		}
 		if existed > 0 {
 			return ErrAlreadyClaimed
 		}
+		progress, err := loadTaskProgress(tx, userID)
+		if err != nil {
+			return err
+		}
+		if reasons := progress.lockReasons(task); len(reasons) > 0 {
+			return ErrTaskLocked
+		}
+		if err := s.verifyCompletion(tx, userID, task, now); err != nil {
+			return err
+		}
 
 		ev := models.UserTaskEvent{
 			UserID:   userID,
@@ -68,6 +122,10 @@ func (s *TaskService) Claim(userID uint, in ClaimInput) (int, error) {
 			EventKey: in.EventKey,
 			MetaJSON: in.MetaJSON,
 		}
+		if !periodStart.IsZero() {
+			// One event per period, enforced by the unique event key.
+			ev.EventKey = fmt.Sprintf("task:%d:%d", task.ID, periodStart.Unix())
+		}
 		if err := tx.Create(&ev).Error; err != nil {
 			if isDuplicate(err) {
 				return ErrAlreadyClaimed
@@ -76,7 +134,7 @@ func (s *TaskService) Claim(userID uint, in ClaimInput) (int, error) {
 		}
 
 		spinCount = int(math.Round(task.RewardAmount))
-		if _, err := s.lotterySvc.AddChancesTx(tx, userID, spinCount); err != nil {
+		if _, err := s.lotterySvc.AddCampaignChancesTx(tx, userID, task.CampaignID, spinCount); err != nil {
 			return err
 		}
 		return s.referralSvc.ProcessFirstValidAction(tx, userID)
@@ -84,12 +142,24 @@ func (s *TaskService) Claim(userID uint, in ClaimInput) (int, error) {
 	if err != nil {
 		return 0, err
 	}
+	s.riskSvc.DetectClaim(userID)
 	return spinCount, nil
 }
 
-func (s *TaskService) ListForUser(userID uint, country string) ([]TaskView, error) {
+func (s *TaskService) ListForUser(userID, campaignID uint, meta RequestMeta) ([]TaskView, error) {
+	user, country, err := s.userLocale(userID, meta)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := loadActiveCampaign(s.db, campaignID, country, time.Now()); err != nil {
+		return nil, err
+	}
 	var tasks []models.Task
-	if err := s.db.Where("enabled = ?", true).Order("id ASC").Find(&tasks).Error; err != nil {
+	if err := s.db.Where("enabled = ? AND campaign_id = ?", true, campaignID).Order("sort_order ASC, id ASC").Find(&tasks).Error; err != nil {
+		return nil, err
+	}
+	progress, err := loadTaskProgress(s.db, userID)
+	if err != nil {
 		return nil, err
 	}
 
@@ -104,20 +174,34 @@ func (s *TaskService) ListForUser(userID uint, country string) ([]TaskView, erro
 		}
 	}
 
+	now := time.Now()
 	out := make([]TaskView, 0, len(tasks))
 	for _, t := range tasks {
-		if country != "" && t.CountryScope != "" && t.CountryScope != "*" && t.CountryScope != country {
+		if !countryInScope(t.CountryScope, country) {
 			continue
 		}
-		ev, claimed := lastByTask[t.ID]
+		ev := lastByTask[t.ID]
+		text := localizeTask(t, user.Language)
+		state, reasons := progress.state(t, s.claimPeriodStart(t.Type, now))
+		prerequisites := parseTaskIDs(t.Prerequisites)
+		if prerequisites == nil {
+			prerequisites = []uint{}
+		}
 		out = append(out, TaskView{
 			ID:                t.ID,
+			CampaignID:        t.CampaignID,
 			Type:              t.Type,
-			Name:              t.Name,
+			Name:              text.Name,
+			Description:       text.Description,
 			RewardAmount:      t.RewardAmount,
 			Enabled:           t.Enabled,
 			CountryScope:      t.CountryScope,
-			Claimed:           claimed,
+			SortOrder:         t.SortOrder,
+			Verification:      s.verificationMode(t.Type),
+			Prerequisites:     prerequisites,
+			State:             state,
+			LockReasons:       reasons,
+			Claimed:           state == TaskStateClaimed,
 			LastClaimEventKey: ev,
 		})
 	}
@@ -126,7 +210,7 @@ func (s *TaskService) ListForUser(userID uint, country string) ([]TaskView, erro
 
 func (s *TaskService) ListAll() ([]models.Task, error) {
 	var tasks []models.Task
-	if err := s.db.Order("id ASC").Find(&tasks).Error; err != nil {
+	if err := s.db.Order("sort_order ASC, id ASC").Find(&tasks).Error; err != nil {
 		return nil, err
 	}
 	return tasks, nil
@@ -135,8 +219,11 @@ func (s *TaskService) ListAll() ([]models.Task, error) {
 func (s *TaskService) SaveTask(in models.Task) (models.Task, error) {
 	in.Type = strings.TrimSpace(in.Type)
 	in.Name = strings.TrimSpace(in.Name)
-	if in.Type == "" {
-		in.Type = "custom"
+	if _, ok := s.registry.Get(in.Type); !ok {
+		return models.Task{}, ErrTaskTypeUnknown
+	}
+	if _, err := parseTaskVerifyParams(in.VerifyParams); err != nil {
+		return models.Task{}, ErrTaskInvalid
 	}
 	if in.Name == "" {
 		in.Name = "未命名任务"
@@ -144,8 +231,29 @@ func (s *TaskService) SaveTask(in models.Task) (models.Task, error) {
 	if in.RewardRuleID == "" {
 		in.RewardRuleID = in.Type
 	}
-	if in.CountryScope == "" {
-		in.CountryScope = "*"
+	in.CountryScope = normalizeCountryScope(in.CountryScope)
+	in.I18n = strings.TrimSpace(in.I18n)
+	if in.I18n != "" {
+		var copies map[string]TaskCopy
+		if err := jsonUnmarshal(in.I18n, &copies); err != nil {
+			return models.Task{}, ErrTaskInvalid
+		}
+	}
+	if _, err := parseTaskUnlockRules(in.UnlockRules); err != nil {
+		return models.Task{}, ErrTaskInvalid
+	}
+	prerequisites := parseTaskIDs(in.Prerequisites)
+	if err := checkTaskPrerequisiteCycle(s.db, in.ID, prerequisites); err != nil {
+		return models.Task{}, err
+	}
+	in.Prerequisites = formatTaskIDs(prerequisites)
+	if in.CampaignID > 0 {
+		if err := s.db.First(&models.Campaign{}, in.CampaignID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return models.Task{}, ErrCampaignNotFound
+			}
+			return models.Task{}, err
+		}
 	}
 	if in.ID == 0 {
 		if err := s.db.Create(&in).Error; err != nil {
@@ -156,10 +264,17 @@ func (s *TaskService) SaveTask(in models.Task) (models.Task, error) {
 	if err := s.db.Model(&models.Task{}).Where("id = ?", in.ID).Updates(map[string]interface{}{
 		"type":           in.Type,
 		"name":           in.Name,
+		"description":    in.Description,
+		"i18n":           in.I18n,
 		"reward_rule_id": in.RewardRuleID,
 		"reward_amount":  in.RewardAmount,
 		"enabled":        in.Enabled,
 		"country_scope":  in.CountryScope,
+		"campaign_id":    in.CampaignID,
+		"sort_order":     in.SortOrder,
+		"prerequisites":  in.Prerequisites,
+		"unlock_rules":   in.UnlockRules,
+		"verify_params":  in.VerifyParams,
 	}).Error; err != nil {
 		return models.Task{}, err
 	}
@@ -173,3 +288,80 @@ func (s *TaskService) SaveTask(in models.Task) (models.Task, error) {
 func (s *TaskService) DeleteTask(id uint) error {
 	return s.db.Delete(&models.Task{}, id).Error
 }
+
+func (s *TaskService) userLocale(userID uint, meta RequestMeta) (models.User, string, error) {
+	return userCountry(s.db, s.geoIP, userID, meta)
+}
+
+func localizeTask(t models.Task, language string) TaskCopy {
+	out := TaskCopy{Name: t.Name, Description: t.Description}
+	var raw map[string]TaskCopy
+	if err := jsonUnmarshal(t.I18n, &raw); err != nil {
+		return out
+	}
+	copies := make(map[string]TaskCopy, len(raw))
+	for k, v := range raw {
+		copies[normalizeLanguage(k)] = v
+	}
+	lang := normalizeLanguage(language)
+	candidates := []string{lang}
+	if i := strings.Index(lang, "-"); i > 0 {
+		candidates = append(candidates, lang[:i])
+	}
+	for _, key := range candidates {
+		if c, ok := copies[key]; ok {
+			if c.Name != "" {
+				out.Name = c.Name
+			}
+			if c.Description != "" {
+				out.Description = c.Description
+			}
+			break
+		}
+	}
+	return out
+}
+
+func normalizeLanguage(lang string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(lang)), "_", "-")
+}
+
+func normalizeCountryScope(scope string) string {
+	seen := map[string]bool{}
+	var items []string
+	for _, item := range strings.Split(scope, ",") {
+		item = strings.ToUpper(strings.ReplaceAll(item, " ", ""))
+		if item == "" || item == "*" || item == "!" || seen[item] {
+			continue
+		}
+		seen[item] = true
+		items = append(items, item)
+	}
+	if len(items) == 0 {
+		return "*"
+	}
+	return strings.Join(items, ",")
+}
+
+// countryInScope treats plain codes as an include list and "!"-prefixed codes
+// as exclusions; an unknown country only passes scopes without includes.
+func countryInScope(scope, country string) bool {
+	country = strings.ToUpper(strings.TrimSpace(country))
+	hasInclude, included := false, false
+	for _, item := range strings.Split(scope, ",") {
+		item = strings.ToUpper(strings.TrimSpace(item))
+		switch {
+		case item == "" || item == "*":
+		case strings.HasPrefix(item, "!"):
+			if country != "" && item[1:] == country {
+				return false
+			}
+		default:
+			hasInclude = true
+			if item == country {
+				included = true
+			}
+		}
+	}
+	return !hasInclude || included
+}
@@ -0,0 +1,201 @@
+package service
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"red_packet/backend/internal/models"
+)
+
+const (
+	TaskStateLocked    = "locked"
+	TaskStateAvailable = "available"
+	TaskStateClaimed   = "claimed"
+)
+
+type TaskUnlockRules struct {
+	MinSpins        int     `json:"min_spins"`
+	MinBalance      float64 `json:"min_balance"`
+	MinInvites      int     `json:"min_invites"`
+	MinValidInvites int     `json:"min_valid_invites"`
+}
+
+type TaskLockReason struct {
+	Rule    string  `json:"rule"`
+	TaskID  uint    `json:"task_id,omitempty"`
+	Current float64 `json:"current"`
+	Target  float64 `json:"target"`
+}
+
+type taskProgress struct {
+	claimed      map[uint]bool
+	lastClaim    map[uint]time.Time
+	enabled      map[uint]bool
+	spins        map[uint]int64
+	balance      float64
+	invites      int64
+	validInvites int64
+}
+
+func parseTaskIDs(raw string) []uint {
+	var out []uint
+	seen := map[uint]bool{}
+	for _, item := range strings.Split(raw, ",") {
+		id, err := strconv.ParseUint(strings.TrimSpace(item), 10, 64)
+		if err != nil || id == 0 || seen[uint(id)] {
+			continue
+		}
+		seen[uint(id)] = true
+		out = append(out, uint(id))
+	}
+	return out
+}
+
+func formatTaskIDs(ids []uint) string {
+	parts := make([]string, 0, len(ids))
+	for _, id := range ids {
+		parts = append(parts, strconv.FormatUint(uint64(id), 10))
+	}
+	return strings.Join(parts, ",")
+}
+
+func parseTaskUnlockRules(raw string) (TaskUnlockRules, error) {
+	var rules TaskUnlockRules
+	if strings.TrimSpace(raw) == "" {
+		return rules, nil
+	}
+	err := jsonUnmarshal(raw, &rules)
+	return rules, err
+}
+
+func loadTaskProgress(tx *gorm.DB, userID uint) (taskProgress, error) {
+	p := taskProgress{claimed: map[uint]bool{}, lastClaim: map[uint]time.Time{}, enabled: map[uint]bool{}, spins: map[uint]int64{}}
+
+	type claimRow struct {
+		TaskID uint
+		Last   time.Time
+	}
+	var claims []claimRow
+	if err := tx.Model(&models.UserTaskEvent{}).Select("task_id, MAX(created_at) AS last").
+		Where("user_id = ?", userID).Group("task_id").Scan(&claims).Error; err != nil {
+		return p, err
+	}
+	for _, r := range claims {
+		p.claimed[r.TaskID] = true
+		p.lastClaim[r.TaskID] = r.Last
+	}
+	var enabled []uint
+	if err := tx.Model(&models.Task{}).Where("enabled = ?", true).Pluck("id", &enabled).Error; err != nil {
+		return p, err
+	}
+	for _, id := range enabled {
+		p.enabled[id] = true
+	}
+
+	type spinRow struct {
+		CampaignID uint
+		Cnt        int64
+	}
+	var spins []spinRow
+	if err := tx.Model(&models.SpinRecord{}).Select("campaign_id, COUNT(*) AS cnt").
+		Where("user_id = ?", userID).Group("campaign_id").Scan(&spins).Error; err != nil {
+		return p, err
+	}
+	for _, r := range spins {
+		p.spins[r.CampaignID] = r.Cnt
+	}
+
+	currency, err := userCurrency(tx, userID)
+	if err != nil {
+		return p, err
+	}
+	var wallet models.Wallet
+	if err := tx.Where("user_id = ? AND currency = ?", userID, currency).Limit(1).Find(&wallet).Error; err != nil {
+		return p, err
+	}
+	p.balance = wallet.Balance
+
+	if err := tx.Model(&models.ReferralEdge{}).Where("parent_user_id = ? AND level = 1", userID).Count(&p.invites).Error; err != nil {
+		return p, err
+	}
+	if err := tx.Model(&models.ReferralEdge{}).Where("parent_user_id = ? AND level = 1 AND is_valid = ?", userID, true).Count(&p.validInvites).Error; err != nil {
+		return p, err
+	}
+	return p, nil
+}
+
+func (p taskProgress) lockReasons(t models.Task) []TaskLockReason {
+	var reasons []TaskLockReason
+	for _, id := range parseTaskIDs(t.Prerequisites) {
+		if p.enabled[id] && !p.claimed[id] {
+			reasons = append(reasons, TaskLockReason{Rule: "prerequisite", TaskID: id, Current: 0, Target: 1})
+		}
+	}
+	rules, err := parseTaskUnlockRules(t.UnlockRules)
+	if err != nil {
+		return reasons
+	}
+	add := func(rule string, current, target float64) {
+		if target > 0 && current < target {
+			reasons = append(reasons, TaskLockReason{Rule: rule, Current: current, Target: target})
+		}
+	}
+	add("spins", float64(p.spins[t.CampaignID]), float64(rules.MinSpins))
+	add("balance", round2(p.balance), rules.MinBalance)
+	add("invites", float64(p.invites), float64(rules.MinInvites))
+	add("valid_invites", float64(p.validInvites), float64(rules.MinValidInvites))
+	return reasons
+}
+
+// state reports the task state; a non-zero periodStart marks a repeatable task
+// that is only claimed when the last claim falls inside the current period.
+func (p taskProgress) state(t models.Task, periodStart time.Time) (string, []TaskLockReason) {
+	if last, ok := p.lastClaim[t.ID]; ok && (periodStart.IsZero() || !last.Before(periodStart)) {
+		return TaskStateClaimed, nil
+	}
+	if reasons := p.lockReasons(t); len(reasons) > 0 {
+		return TaskStateLocked, reasons
+	}
+	return TaskStateAvailable, nil
+}
+
+func checkTaskPrerequisiteCycle(tx *gorm.DB, taskID uint, prerequisites []uint) error {
+	if len(prerequisites) == 0 {
+		return nil
+	}
+	var tasks []models.Task
+	if err := tx.Select("id", "prerequisites").Find(&tasks).Error; err != nil {
+		return err
+	}
+	graph := make(map[uint][]uint, len(tasks))
+	for _, t := range tasks {
+		graph[t.ID] = parseTaskIDs(t.Prerequisites)
+	}
+	for _, id := range prerequisites {
+		if _, ok := graph[id]; !ok || id == taskID {
+			return ErrTaskInvalid
+		}
+	}
+	if taskID == 0 {
+		return nil
+	}
+	graph[taskID] = prerequisites
+	visited := map[uint]bool{}
+	stack := append([]uint{}, prerequisites...)
+	for len(stack) > 0 {
+		id := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if id == taskID {
+			return ErrTaskPrerequisiteCycle
+		}
+		if visited[id] {
+			continue
+		}
+		visited[id] = true
+		stack = append(stack, graph[id]...)
+	}
+	return nil
+}
@@ -0,0 +1,376 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"red_packet/backend/internal/models"
+)
+
+const (
+	TaskVerifyInternal = "internal"
+	TaskVerifyCallback = "callback"
+)
+
+type TaskVerifyParams struct {
+	Count     int      `json:"count"`
+	ValidOnly bool     `json:"valid_only"`
+	Statuses  []string `json:"statuses"`
+}
+
+type TaskVerifyInput struct {
+	UserID uint
+	Task   models.Task
+	Params TaskVerifyParams
+	Now    time.Time
+}
+
+type TaskVerifier interface {
+	Type() string
+	Mode() string
+	Verify(tx *gorm.DB, in TaskVerifyInput) (bool, string, error)
+}
+
+// TaskRepeater is implemented by verifiers whose tasks can be claimed again in
+// every period; PeriodStart returns the start of the period containing now.
+type TaskRepeater interface {
+	PeriodStart(now time.Time) time.Time
+}
+
+type TaskTypeView struct {
+	Type string `json:"type"`
+	Mode string `json:"mode"`
+}
+
+type TaskCallbackInput struct {
+	UserID      uint   `json:"user_id"`
+	TaskID      uint   `json:"task_id"`
+	ExternalRef string `json:"external_ref"`
+	Timestamp   int64  `json:"ts"`
+	Sign        string `json:"sign"`
+	Payload     string `json:"payload"`
+}
+
+type TaskRegistry struct {
+	verifiers map[string]TaskVerifier
+	order     []string
+}
+
+func NewTaskRegistry() *TaskRegistry {
+	r := &TaskRegistry{verifiers: map[string]TaskVerifier{}}
+	r.Register(checkinVerifier{})
+	r.Register(shareVerifier{})
+	r.Register(inviteVerifier{})
+	r.Register(spinVerifier{})
+	r.Register(firstWithdrawVerifier{})
+	r.Register(callbackVerifier{name: "ad_view"})
+	r.Register(callbackVerifier{name: "app_install"})
+	r.Register(callbackVerifier{name: "custom"})
+	return r
+}
+
+func (r *TaskRegistry) Register(v TaskVerifier) {
+	if _, exists := r.verifiers[v.Type()]; !exists {
+		r.order = append(r.order, v.Type())
+	}
+	r.verifiers[v.Type()] = v
+}
+
+func (r *TaskRegistry) Get(taskType string) (TaskVerifier, bool) {
+	v, ok := r.verifiers[taskType]
+	return v, ok
+}
+
+func (r *TaskRegistry) Types() []TaskTypeView {
+	out := make([]TaskTypeView, 0, len(r.order))
+	for _, name := range r.order {
+		out = append(out, TaskTypeView{Type: name, Mode: r.verifiers[name].Mode()})
+	}
+	return out
+}
+
+func parseTaskVerifyParams(raw string) (TaskVerifyParams, error) {
+	var params TaskVerifyParams
+	if strings.TrimSpace(raw) == "" {
+		return params, nil
+	}
+	err := jsonUnmarshal(raw, &params)
+	return params, err
+}
+
+func (s *TaskService) verifyCompletion(tx *gorm.DB, userID uint, task models.Task, now time.Time) error {
+	verifier, ok := s.registry.Get(task.Type)
+	if !ok {
+		return fmt.Errorf("%w: unknown task type %q", ErrTaskUnverified, task.Type)
+	}
+	params, err := parseTaskVerifyParams(task.VerifyParams)
+	if err != nil {
+		return fmt.Errorf("%w: invalid verify params", ErrTaskUnverified)
+	}
+	passed, reason, err := verifier.Verify(tx, TaskVerifyInput{UserID: userID, Task: task, Params: params, Now: now})
+	if err != nil {
+		return err
+	}
+	if !passed {
+		return fmt.Errorf("%w: %s", ErrTaskUnverified, reason)
+	}
+	return nil
+}
+
+func (s *TaskService) verificationMode(taskType string) string {
+	if v, ok := s.registry.Get(taskType); ok {
+		return v.Mode()
+	}
+	return ""
+}
+
+// claimPeriodStart is the start of the current claim period for repeatable
+// task types, or the zero time for tasks claimed only once.
+func (s *TaskService) claimPeriodStart(taskType string, now time.Time) time.Time {
+	if v, ok := s.registry.Get(taskType); ok {
+		if r, ok := v.(TaskRepeater); ok {
+			return r.PeriodStart(now)
+		}
+	}
+	return time.Time{}
+}
+
+func (s *TaskService) TaskTypes() []TaskTypeView {
+	return s.registry.Types()
+}
+
+// DisableUnregisteredTasks runs at startup: enabled tasks whose type has no
+// verifier could never be claimed, so they are disabled and reported until an
+// admin re-saves them with a registered type.
+func (s *TaskService) DisableUnregisteredTasks() (int, error) {
+	var tasks []models.Task
+	if err := s.db.Select("id", "type", "name").Where("enabled = ?", true).Find(&tasks).Error; err != nil {
+		return 0, err
+	}
+	var ids []uint
+	for _, t := range tasks {
+		if _, ok := s.registry.Get(t.Type); !ok {
+			ids = append(ids, t.ID)
+			log.Printf("ERROR task %d (%s) has unregistered type %q, disabled until re-saved with a registered type", t.ID, t.Name, t.Type)
+		}
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	err := s.db.Model(&models.Task{}).Where("id IN ?", ids).Update("enabled", false).Error
+	return len(ids), err
+}
+
+func (s *TaskService) RecordCallback(taskType string, in TaskCallbackInput) (models.TaskCompletion, error) {
+	verifier, ok := s.registry.Get(taskType)
+	if !ok || verifier.Mode() != TaskVerifyCallback {
+		return models.TaskCompletion{}, ErrTaskCallbackInvalid
+	}
+	secret := s.callbackSecrets[taskType]
+	in.ExternalRef = strings.TrimSpace(in.ExternalRef)
+	if secret == "" || in.UserID == 0 || in.TaskID == 0 || in.ExternalRef == "" {
+		return models.TaskCompletion{}, ErrTaskCallbackInvalid
+	}
+	skew := loadConfigInt(s.db, "task_callback_max_skew_seconds", 600)
+	if d := time.Since(time.Unix(in.Timestamp, 0)); skew > 0 && (d > time.Duration(skew)*time.Second || d < -time.Duration(skew)*time.Second) {
+		return models.TaskCompletion{}, ErrTaskCallbackSignature
+	}
+	if !hmac.Equal([]byte(strings.ToLower(in.Sign)), []byte(taskCallbackSign(secret, in))) {
+		return models.TaskCompletion{}, ErrTaskCallbackSignature
+	}
+
+	var task models.Task
+	if err := s.db.Where("id = ? AND type = ?", in.TaskID, taskType).First(&task).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return models.TaskCompletion{}, ErrTaskNotFound
+		}
+		return models.TaskCompletion{}, err
+	}
+	if err := s.db.Select("id").First(&models.User{}, in.UserID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return models.TaskCompletion{}, ErrTaskCallbackInvalid
+		}
+		return models.TaskCompletion{}, err
+	}
+	completion := models.TaskCompletion{
+		TaskID:      in.TaskID,
+		UserID:      in.UserID,
+		Source:      taskType,
+		ExternalRef: in.ExternalRef,
+		Payload:     in.Payload,
+	}
+	if err := s.db.Create(&completion).Error; err != nil {
+		if !isDuplicate(err) {
+			return models.TaskCompletion{}, err
+		}
+		if err := s.db.Where("task_id = ? AND external_ref = ?", in.TaskID, in.ExternalRef).First(&completion).Error; err != nil {
+			return models.TaskCompletion{}, err
+		}
+		if completion.UserID != in.UserID {
+			return models.TaskCompletion{}, ErrTaskCallbackInvalid
+		}
+	}
+	return completion, nil
+}
+
+func taskCallbackSign(secret string, in TaskCallbackInput) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d:%d:%s:%d", in.UserID, in.TaskID, in.ExternalRef, in.Timestamp)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func minCount(params TaskVerifyParams) int {
+	if params.Count < 1 {
+		return 1
+	}
+	return params.Count
+}
+
+type checkinVerifier struct{}
+
+func (checkinVerifier) Type() string { return "checkin" }
+func (checkinVerifier) Mode() string { return TaskVerifyInternal }
+
+// PeriodStart makes check-in tasks claimable once per server-local day.
+func (checkinVerifier) PeriodStart(now time.Time) time.Time {
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+}
+
+// Verify allows one check-in per day across all check-in tasks (e.g. a global
+// and a campaign check-in).
+func (v checkinVerifier) Verify(tx *gorm.DB, in TaskVerifyInput) (bool, string, error) {
+	dayStart := v.PeriodStart(in.Now)
+	var count int64
+	if err := tx.Model(&models.UserTaskEvent{}).
+		Joins("JOIN tasks ON tasks.id = user_task_events.task_id").
+		Where("user_task_events.user_id = ? AND tasks.type = ? AND user_task_events.created_at >= ?", in.UserID, "checkin", dayStart).
+		Count(&count).Error; err != nil {
+		return false, "", err
+	}
+	if count > 0 {
+		return false, "already checked in today", nil
+	}
+	return true, "", nil
+}
+
+type shareVerifier struct{}
+
+func (shareVerifier) Type() string { return "share" }
+func (shareVerifier) Mode() string { return TaskVerifyInternal }
+
+// Verify counts distinct visitor IPs on the inviter's share link. Visits from
+// the inviter's own registration IP, any IP the inviter has used, or the
+// inviter's device do not count, so clicking one's own link does not pass.
+func (shareVerifier) Verify(tx *gorm.DB, in TaskVerifyInput) (bool, string, error) {
+	var inviter models.User
+	if err := tx.Select("id", "device_hash", "register_ip").First(&inviter, in.UserID).Error; err != nil {
+		return false, "", err
+	}
+	q := tx.Model(&models.ReferralClick{}).
+		Where("inviter_user_id = ? AND ip <> ''", in.UserID).
+		Where("ip NOT IN (?)", tx.Model(&models.RiskDecision{}).Select("ip").Where("user_id = ? AND ip <> ''", in.UserID))
+	if inviter.RegisterIP != "" {
+		q = q.Where("ip <> ?", inviter.RegisterIP)
+	}
+	if inviter.DeviceHash != "" {
+		q = q.Where("device_hash <> ?", inviter.DeviceHash)
+	}
+	var count int64
+	if err := q.Distinct("ip").Count(&count).Error; err != nil {
+		return false, "", err
+	}
+	if target := minCount(in.Params); count < int64(target) {
+		return false, fmt.Sprintf("share link visitors %d/%d", count, target), nil
+	}
+	return true, "", nil
+}
+
+type inviteVerifier struct{}
+
+func (inviteVerifier) Type() string { return "invite_n" }
+func (inviteVerifier) Mode() string { return TaskVerifyInternal }
+
+func (inviteVerifier) Verify(tx *gorm.DB, in TaskVerifyInput) (bool, string, error) {
+	q := tx.Model(&models.ReferralEdge{}).Where("parent_user_id = ? AND level = 1", in.UserID)
+	if in.Params.ValidOnly {
+		q = q.Where("is_valid = ?", true)
+	}
+	var count int64
+	if err := q.Count(&count).Error; err != nil {
+		return false, "", err
+	}
+	if target := minCount(in.Params); count < int64(target) {
+		return false, fmt.Sprintf("invites %d/%d", count, target), nil
+	}
+	return true, "", nil
+}
+
+type spinVerifier struct{}
+
+func (spinVerifier) Type() string { return "spin_n" }
+func (spinVerifier) Mode() string { return TaskVerifyInternal }
+
+func (spinVerifier) Verify(tx *gorm.DB, in TaskVerifyInput) (bool, string, error) {
+	var count int64
+	if err := tx.Model(&models.SpinRecord{}).
+		Where("user_id = ? AND campaign_id = ?", in.UserID, in.Task.CampaignID).
+		Count(&count).Error; err != nil {
+		return false, "", err
+	}
+	if target := minCount(in.Params); count < int64(target) {
+		return false, fmt.Sprintf("spins %d/%d", count, target), nil
+	}
+	return true, "", nil
+}
+
+type firstWithdrawVerifier struct{}
+
+func (firstWithdrawVerifier) Type() string { return "first_withdraw" }
+func (firstWithdrawVerifier) Mode() string { return TaskVerifyInternal }
+
+func (firstWithdrawVerifier) Verify(tx *gorm.DB, in TaskVerifyInput) (bool, string, error) {
+	statuses := in.Params.Statuses
+	if len(statuses) == 0 {
+		statuses = []string{"approved", "paid"}
+	}
+	var count int64
+	if err := tx.Model(&models.WithdrawRequest{}).
+		Where("user_id = ? AND status IN ?", in.UserID, statuses).
+		Count(&count).Error; err != nil {
+		return false, "", err
+	}
+	if count == 0 {
+		sort.Strings(statuses)
+		return false, "no withdraw in status " + strings.Join(statuses, "/"), nil
+	}
+	return true, "", nil
+}
+
+type callbackVerifier struct {
+	name string
+}
+
+func (v callbackVerifier) Type() string { return v.name }
+func (callbackVerifier) Mode() string   { return TaskVerifyCallback }
+
+func (callbackVerifier) Verify(tx *gorm.DB, in TaskVerifyInput) (bool, string, error) {
+	var count int64
+	if err := tx.Model(&models.TaskCompletion{}).
+		Where("task_id = ? AND user_id = ?", in.Task.ID, in.UserID).
+		Count(&count).Error; err != nil {
+		return false, "", err
+	}
+	if count == 0 {
+		return false, "completion callback not received", nil
+	}
+	return true, "", nil
+}
@@ -0,0 +1,66 @@
+package service
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"red_packet/backend/internal/models"
+)
+
+func TestShareVerifierIgnoresInviterVisits(t *testing.T) {
+	db := openTestDB(t)
+	suffix := time.Now().UnixNano()
+	inviter := models.User{DeviceHash: fmt.Sprintf("share-%d", suffix), RegisterIP: "10.1.0.1"}
+	if err := db.Create(&inviter).Error; err != nil {
+		t.Fatalf("create inviter: %v", err)
+	}
+	if err := db.Create(&models.RiskDecision{UserID: inviter.ID, Action: RiskActionSpin, IP: "10.1.0.2"}).Error; err != nil {
+		t.Fatalf("create decision: %v", err)
+	}
+	click := func(ip, device string) {
+		t.Helper()
+		c := models.ReferralClick{InviterUserID: inviter.ID, Token: fmt.Sprintf("%d-%s-%s", suffix, ip, device), IP: ip, DeviceHash: device}
+		if err := db.Create(&c).Error; err != nil {
+			t.Fatalf("create click: %v", err)
+		}
+	}
+	// Own registration IP, an IP the inviter used, and the inviter's device
+	// from another IP; plus repeat visits from one outside IP.
+	click("10.1.0.1", "")
+	click("10.1.0.2", "")
+	click("10.1.0.3", inviter.DeviceHash)
+	click("10.2.0.1", "a")
+	click("10.2.0.1", "b")
+
+	in := TaskVerifyInput{UserID: inviter.ID, Params: TaskVerifyParams{Count: 2}, Now: time.Now()}
+	if ok, reason, err := (shareVerifier{}).Verify(db, in); err != nil || ok {
+		t.Fatalf("verify = %v %q %v, want not passed", ok, reason, err)
+	}
+	click("10.2.0.2", "")
+	if ok, reason, err := (shareVerifier{}).Verify(db, in); err != nil || !ok {
+		t.Fatalf("verify = %v %q %v, want passed", ok, reason, err)
+	}
+}
+
+func TestTaskProgressStateRepeatsPerPeriod(t *testing.T) {
+	now := time.Date(2026, 3, 2, 10, 0, 0, 0, time.Local)
+	period := checkinVerifier{}.PeriodStart(now)
+	task := models.Task{ID: 1}
+	cases := []struct {
+		name   string
+		last   time.Time
+		period time.Time
+		want   string
+	}{
+		{"once-only claimed", now.AddDate(0, 0, -3), time.Time{}, TaskStateClaimed},
+		{"claimed yesterday", now.AddDate(0, 0, -1), period, TaskStateAvailable},
+		{"claimed today", period.Add(time.Minute), period, TaskStateClaimed},
+	}
+	for _, c := range cases {
+		p := taskProgress{claimed: map[uint]bool{1: true}, lastClaim: map[uint]time.Time{1: c.last}}
+		if got, _ := p.state(task, c.period); got != c.want {
+			t.Errorf("%s: state = %s, want %s", c.name, got, c.want)
+		}
+	}
+}
@@ -9,9 +9,11 @@ import (
 )
 
 type WalletView struct {
-	Balance float64               `json:"balance"`
-	Frozen  float64               `json:"frozen"`
-	Ledgers []models.WalletLedger `json:"ledgers"`
+	Currency string                `json:"currency"`
+	Balance  float64               `json:"balance"`
+	Frozen   float64               `json:"frozen"`
+	Wallets  []models.Wallet       `json:"wallets"`
+	Ledgers  []models.WalletLedger `json:"ledgers"`
 }
 
 type WalletService struct {
@@ -22,28 +24,39 @@ func NewWalletService(db *gorm.DB) *WalletService {
 	return &WalletService{db: db}
 }
 
-func (s *WalletService) Get(userID uint, page, size int) (WalletView, error) {
+func (s *WalletService) Get(userID uint, currency string, page, size int) (WalletView, error) {
 	if page < 1 {
 		page = 1
 	}
 	if size < 1 || size > 100 {
 		size = 20
 	}
+	currency = normalizeCurrency(currency)
+	if currency == "" {
+		var err error
+		if currency, err = userCurrency(s.db, userID); err != nil {
+			return WalletView{}, err
+		}
+	}
 	var wallet models.Wallet
-	err := s.db.Where("user_id = ?", userID).First(&wallet).Error
+	err := s.db.Where("user_id = ? AND currency = ?", userID, currency).First(&wallet).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			wallet = models.Wallet{UserID: userID}
-			if e := s.db.Create(&wallet).Error; e != nil {
+			wallet = models.Wallet{UserID: userID, Currency: currency}
+			if e := s.db.Create(&wallet).Error; e != nil && !isDuplicate(e) {
 				return WalletView{}, e
 			}
 		} else {
 			return WalletView{}, err
 		}
 	}
+	var wallets []models.Wallet
+	if err := s.db.Where("user_id = ?", userID).Order("currency ASC").Find(&wallets).Error; err != nil {
+		return WalletView{}, err
+	}
 
 	var ledgers []models.WalletLedger
-	if err := s.db.Where("user_id = ?", userID).
+	if err := s.db.Where("user_id = ? AND currency = ?", userID, currency).
 		Order("id DESC").
 		Offset((page - 1) * size).
 		Limit(size).
@@ -51,8 +64,10 @@ func (s *WalletService) Get(userID uint, page, size int) (WalletView, error) {
 		return WalletView{}, err
 	}
 	return WalletView{
-		Balance: wallet.Balance,
-		Frozen:  wallet.Frozen,
-		Ledgers: ledgers,
+		Currency: currency,
+		Balance:  wallet.Balance,
+		Frozen:   wallet.Frozen,
+		Wallets:  wallets,
+		Ledgers:  ledgers,
 	}, nil
 }
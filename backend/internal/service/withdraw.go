@@ -4,37 +4,55 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log"
 	"strconv"
+	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
 	"red_packet/backend/internal/models"
 )
 
 type WithdrawService struct {
-	db      *gorm.DB
-	riskSvc *RiskService
+	db        *gorm.DB
+	riskSvc   *RiskService
+	payoutSvc *PayoutService
 }
 
-func NewWithdrawService(db *gorm.DB, riskSvc *RiskService) *WithdrawService {
-	return &WithdrawService{db: db, riskSvc: riskSvc}
+func NewWithdrawService(db *gorm.DB, riskSvc *RiskService, payoutSvc *PayoutService) *WithdrawService {
+	return &WithdrawService{db: db, riskSvc: riskSvc, payoutSvc: payoutSvc}
 }
 
-func (s *WithdrawService) Apply(userID uint, amount float64) (models.WithdrawRequest, error) {
+func (s *WithdrawService) Apply(userID uint, amount float64, accountID uint, currency string, meta RequestMeta) (models.WithdrawRequest, error) {
 	var req models.WithdrawRequest
 	if amount <= 0 {
 		return req, ErrInvalidAmount
 	}
-	minAmount := s.loadWithdrawMin()
-	if amount < minAmount {
-		return req, ErrWithdrawBelowMin
-	}
-	if err := s.riskSvc.CheckWithdrawEligibility(userID); err != nil {
+	decision, err := s.riskSvc.CheckWithdrawEligibility(userID, meta)
+	if err != nil {
 		return req, err
 	}
-	err := s.db.Transaction(func(tx *gorm.DB) error {
-		var wallet models.Wallet
-		if err := tx.Where("user_id = ?", userID).First(&wallet).Error; err != nil {
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id", "country").First(&user, userID).Error; err != nil {
+			return err
+		}
+		account, err := s.payoutSvc.ResolveForWithdraw(tx, userID, accountID)
+		if err != nil {
+			return err
+		}
+		quote, err := s.quoteTx(tx, userID, account, amount, currency)
+		if err != nil {
+			return err
+		}
+		wallet, err := lockWallet(tx, userID, quote.Currency)
+		if err != nil {
+			return err
+		}
+		policy := loadWithdrawPolicy(tx, withdrawMinForCurrency(tx, quote.Currency, s.loadWithdrawMin()))
+		policy.Currency = quote.Currency
+		if err := policy.Check(tx, userID, amount, time.Now()); err != nil {
 			return err
 		}
 		if wallet.Balance < amount {
@@ -47,19 +65,31 @@ func (s *WithdrawService) Apply(userID uint, amount float64) (models.WithdrawReq
 		}
 
 		req = models.WithdrawRequest{
-			UserID: userID,
-			Amount: amount,
-			Status: "pending",
+			UserID:            userID,
+			Amount:            amount,
+			Currency:          quote.Currency,
+			Fee:               quote.Fee,
+			NetAmount:         quote.Net,
+			Status:            "pending",
+			PayoutAccountID:   account.ID,
+			PayoutMethod:      account.Method,
+			PayoutProvider:    account.Provider,
+			PayoutAccountName: account.AccountName,
+			PayoutAccountNo:   account.AccountNo,
+		}
+		if decision.Decision == RiskReview {
+			req.Note = fmt.Sprintf("risk review: score %d (decision #%d)", decision.Score, decision.ID)
 		}
 		if err := tx.Create(&req).Error; err != nil {
 			return err
 		}
 		ledger := models.WalletLedger{
-			UserID:  userID,
-			Amount:  -amount,
-			Type:    "withdraw_freeze",
-			RefType: "withdraw_request",
-			RefID:   fmt.Sprintf("%d", req.ID),
+			UserID:   userID,
+			Amount:   -amount,
+			Currency: quote.Currency,
+			Type:     "withdraw_freeze",
+			RefType:  "withdraw_request",
+			RefID:    fmt.Sprintf("%d", req.ID),
 		}
 		if err := tx.Create(&ledger).Error; err != nil {
 			if isDuplicate(err) {
@@ -75,9 +105,44 @@ func (s *WithdrawService) Apply(userID uint, amount float64) (models.WithdrawReq
 		}
 		return req, err
 	}
+	s.riskSvc.DetectWithdraw(userID, req.ID)
 	return req, nil
 }
 
+func (s *WithdrawService) Quote(userID uint, amount float64, accountID uint, currency string) (WithdrawQuote, error) {
+	if amount <= 0 {
+		return WithdrawQuote{}, ErrInvalidAmount
+	}
+	account, err := s.payoutSvc.FindActive(s.db, userID, accountID)
+	if err != nil {
+		return WithdrawQuote{}, err
+	}
+	return s.quoteTx(s.db, userID, account, amount, currency)
+}
+
+func (s *WithdrawService) quoteTx(tx *gorm.DB, userID uint, account models.PayoutAccount, amount float64, currency string) (WithdrawQuote, error) {
+	country := account.Country
+	if country == "" {
+		var user models.User
+		if err := tx.Select("country").First(&user, userID).Error; err != nil {
+			return WithdrawQuote{}, err
+		}
+		country = user.Country
+	}
+	currency = normalizeCurrency(currency)
+	if currency == "" {
+		var err error
+		if currency, err = userCurrency(tx, userID); err != nil {
+			return WithdrawQuote{}, err
+		}
+	}
+	quote := calcWithdrawQuote(loadWithdrawFeeRules(tx), amount, account.Method, country, currency)
+	if quote.Net <= 0 {
+		return quote, ErrWithdrawFeeExceeds
+	}
+	return quote, nil
+}
+
 func (s *WithdrawService) loadWithdrawMin() float64 {
 	var cfg models.AppConfig
 	if err := s.db.Where("`key` = ?", "withdraw_min").First(&cfg).Error; err == nil {
@@ -109,6 +174,9 @@ func parseFirstTargetAmount(raw string) float64 {
 }
 
 func (s *WithdrawService) UpdateStatus(requestID uint, status string, note string) (models.WithdrawRequest, error) {
+	if status == "cancelled" {
+		return models.WithdrawRequest{}, ErrWithdrawState
+	}
 	var req models.WithdrawRequest
 	err := s.db.Transaction(func(tx *gorm.DB) error {
 		if err := tx.First(&req, requestID).Error; err != nil {
@@ -117,74 +185,178 @@ func (s *WithdrawService) UpdateStatus(requestID uint, status string, note strin
 			}
 			return err
 		}
-
-		if !validWithdrawTransition(req.Status, status) {
+		// Admins may only expire (and refund) approved requests that breached
+		// the SLA, after confirming the channel never paid them out.
+		if status == "expired" && (req.Status != "approved" || req.SLABreachedAt == nil) {
 			return ErrWithdrawState
 		}
-		oldStatus := req.Status
-		req.Status = status
-		req.Note = note
-		if err := tx.Save(&req).Error; err != nil {
-			return err
-		}
+		return s.transitionTx(tx, &req, status, note)
+	})
+	if err != nil {
+		return models.WithdrawRequest{}, err
+	}
+	return req, nil
+}
 
-		var wallet models.Wallet
-		if err := tx.Where("user_id = ?", req.UserID).First(&wallet).Error; err != nil {
+func (s *WithdrawService) Cancel(userID, requestID uint) (models.WithdrawRequest, error) {
+	var req models.WithdrawRequest
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ? AND user_id = ?", requestID, userID).First(&req).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrWithdrawNotFound
+			}
 			return err
 		}
+		return s.transitionTx(tx, &req, "cancelled", "cancelled by user")
+	})
+	if err != nil {
+		return models.WithdrawRequest{}, err
+	}
+	return req, nil
+}
 
-		switch {
-		case oldStatus == "pending" && status == "rejected":
-			wallet.Balance += req.Amount
-			wallet.Frozen -= req.Amount
-			if wallet.Frozen < 0 {
-				wallet.Frozen = 0
-			}
-			if err := tx.Save(&wallet).Error; err != nil {
-				return err
-			}
-			ledger := models.WalletLedger{
-				UserID:  req.UserID,
-				Amount:  req.Amount,
-				Type:    "withdraw_reject_refund",
-				RefType: "withdraw_request_reject",
-				RefID:   fmt.Sprintf("%d", req.ID),
-			}
-			if err := tx.Create(&ledger).Error; err != nil && !isDuplicate(err) {
-				return err
-			}
-		case oldStatus == "approved" && status == "paid":
-			wallet.Frozen -= req.Amount
-			if wallet.Frozen < 0 {
-				wallet.Frozen = 0
-			}
-			if err := tx.Save(&wallet).Error; err != nil {
-				return err
+// ExpireStale expires and refunds pending requests older than the pending SLA.
+func (s *WithdrawService) ExpireStale(now time.Time) (int, error) {
+	pendingSLA := time.Duration(loadConfigFloat(s.db, "withdraw_pending_sla_hours", 72) * float64(time.Hour))
+	if pendingSLA <= 0 {
+		return 0, nil
+	}
+
+	var stale []models.WithdrawRequest
+	if err := s.db.Where("status = ? AND updated_at < ?", "pending", now.Add(-pendingSLA)).
+		Order("id ASC").Limit(500).Find(&stale).Error; err != nil {
+		return 0, err
+	}
+
+	expired := 0
+	for i := range stale {
+		err := s.db.Transaction(func(tx *gorm.DB) error {
+			return s.transitionTx(tx, &stale[i], "expired", "expired after sla")
+		})
+		if err != nil {
+			if errors.Is(err, ErrWithdrawState) {
+				continue
 			}
-			ledger := models.WalletLedger{
-				UserID:  req.UserID,
-				Amount:  0,
-				Type:    "withdraw_paid",
-				RefType: "withdraw_request_paid",
-				RefID:   fmt.Sprintf("%d", req.ID),
+			return expired, err
+		}
+		expired++
+	}
+	return expired, nil
+}
+
+// FlagStaleApproved marks approved requests older than the approved SLA. They
+// may already be paid out upstream, so refunding them automatically could pay
+// twice; an admin reconciles and then marks them paid or expired.
+func (s *WithdrawService) FlagStaleApproved(now time.Time) (int, error) {
+	approvedSLA := time.Duration(loadConfigFloat(s.db, "withdraw_approved_sla_hours", 168) * float64(time.Hour))
+	if approvedSLA <= 0 {
+		return 0, nil
+	}
+	res := s.db.Model(&models.WithdrawRequest{}).
+		Where("status = ? AND updated_at < ? AND sla_breached_at IS NULL", "approved", now.Add(-approvedSLA)).
+		Update("sla_breached_at", now)
+	if res.Error != nil {
+		return 0, res.Error
+	}
+	if res.RowsAffected > 0 {
+		log.Printf("withdraw: %d approved requests exceeded sla, reconcile payouts", res.RowsAffected)
+	}
+	return int(res.RowsAffected), nil
+}
+
+func (s *WithdrawService) transitionTx(tx *gorm.DB, req *models.WithdrawRequest, status string, note string) error {
+	if !validWithdrawTransition(req.Status, status) {
+		return ErrWithdrawState
+	}
+	oldStatus := req.Status
+	res := tx.Model(&models.WithdrawRequest{}).
+		Where("id = ? AND status = ?", req.ID, oldStatus).
+		Updates(map[string]interface{}{"status": status, "note": note})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrWithdrawState
+	}
+	req.Status = status
+	req.Note = note
+
+	wallet, err := lockWallet(tx, req.UserID, req.Currency)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case oldStatus == "pending" && status == "rejected":
+		return refundWithdrawTx(tx, &wallet, req, "withdraw_reject_refund", "withdraw_request_reject")
+	case status == "cancelled":
+		return refundWithdrawTx(tx, &wallet, req, "withdraw_cancel_refund", "withdraw_request_cancel")
+	case status == "expired":
+		return refundWithdrawTx(tx, &wallet, req, "withdraw_expire_refund", "withdraw_request_expire")
+	case oldStatus == "approved" && status == "paid":
+		wallet.Frozen -= req.Amount
+		if wallet.Frozen < 0 {
+			wallet.Frozen = 0
+		}
+		if err := tx.Save(&wallet).Error; err != nil {
+			return err
+		}
+		ledger := models.WalletLedger{
+			UserID:   req.UserID,
+			Amount:   0,
+			Currency: req.Currency,
+			Type:     "withdraw_paid",
+			RefType:  "withdraw_request_paid",
+			RefID:    fmt.Sprintf("%d", req.ID),
+		}
+		if err := tx.Create(&ledger).Error; err != nil && !isDuplicate(err) {
+			return err
+		}
+		if req.Fee > 0 {
+			revenue := models.PlatformLedger{
+				Account:  "withdraw_fee",
+				Amount:   req.Fee,
+				Currency: req.Currency,
+				UserID:   req.UserID,
+				RefType:  "withdraw_request_paid",
+				RefID:    fmt.Sprintf("%d", req.ID),
 			}
-			if err := tx.Create(&ledger).Error; err != nil && !isDuplicate(err) {
+			if err := tx.Create(&revenue).Error; err != nil && !isDuplicate(err) {
 				return err
 			}
 		}
-		return nil
-	})
-	if err != nil {
-		return models.WithdrawRequest{}, err
 	}
-	return req, nil
+	return nil
+}
+
+func refundWithdrawTx(tx *gorm.DB, wallet *models.Wallet, req *models.WithdrawRequest, ledgerType, refType string) error {
+	wallet.Balance += req.Amount
+	wallet.Frozen -= req.Amount
+	if wallet.Frozen < 0 {
+		wallet.Frozen = 0
+	}
+	if err := tx.Save(wallet).Error; err != nil {
+		return err
+	}
+	ledger := models.WalletLedger{
+		UserID:   req.UserID,
+		Amount:   req.Amount,
+		Currency: req.Currency,
+		Type:     ledgerType,
+		RefType:  refType,
+		RefID:    fmt.Sprintf("%d", req.ID),
+	}
+	if err := tx.Create(&ledger).Error; err != nil && !isDuplicate(err) {
+		return err
+	}
+	return nil
 }
 
 func validWithdrawTransition(from string, to string) bool {
-	if from == "pending" && (to == "approved" || to == "rejected") {
+	if from == "pending" && (to == "approved" || to == "rejected" || to == "cancelled" || to == "expired") {
 		return true
 	}
-	if from == "approved" && to == "paid" {
+	if from == "approved" && (to == "paid" || to == "expired") {
 		return true
 	}
 	return false
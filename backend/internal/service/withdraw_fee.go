@@ -0,0 +1,91 @@
+package service
+
+import (
+	"math"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+type WithdrawFeeRule struct {
+	Method   string  `json:"method"`
+	Country  string  `json:"country"`
+	Currency string  `json:"currency"`
+	Flat     float64 `json:"flat"`
+	Percent  float64 `json:"percent"`
+	Min      float64 `json:"min"`
+	Max      float64 `json:"max"`
+}
+
+type WithdrawQuote struct {
+	Gross    float64 `json:"gross"`
+	Fee      float64 `json:"fee"`
+	Net      float64 `json:"net"`
+	Currency string  `json:"currency"`
+	Method   string  `json:"method"`
+	Country  string  `json:"country"`
+}
+
+func loadWithdrawFeeRules(db *gorm.DB) []WithdrawFeeRule {
+	var rules []WithdrawFeeRule
+	_ = jsonUnmarshal(loadConfigString(db, "withdraw_fee_rules", ""), &rules)
+	return rules
+}
+
+func matchWithdrawFeeRule(rules []WithdrawFeeRule, method, country, currency string) (WithdrawFeeRule, bool) {
+	best := -1
+	var out WithdrawFeeRule
+	for _, r := range rules {
+		score := 0
+		switch {
+		case r.Method == "" || r.Method == "*":
+		case strings.EqualFold(r.Method, method):
+			score += 4
+		default:
+			continue
+		}
+		switch {
+		case r.Currency == "" || r.Currency == "*":
+		case strings.EqualFold(r.Currency, currency):
+			score += 2
+		default:
+			continue
+		}
+		switch {
+		case r.Country == "" || r.Country == "*":
+		case strings.EqualFold(r.Country, country):
+			score++
+		default:
+			continue
+		}
+		if score > best {
+			best = score
+			out = r
+		}
+	}
+	return out, best >= 0
+}
+
+func calcWithdrawQuote(rules []WithdrawFeeRule, gross float64, method, country, currency string) WithdrawQuote {
+	quote := WithdrawQuote{Gross: gross, Net: gross, Currency: currency, Method: method, Country: country}
+	rule, ok := matchWithdrawFeeRule(rules, method, country, currency)
+	if !ok {
+		return quote
+	}
+	fee := rule.Flat + gross*rule.Percent/100
+	if rule.Min > 0 && fee < rule.Min {
+		fee = rule.Min
+	}
+	if rule.Max > 0 && fee > rule.Max {
+		fee = rule.Max
+	}
+	// Round to the stored precision before taking the ceiling cent so float
+	// noise (7*1% = 0.07000000000000001) does not add a cent.
+	fee = math.Ceil(math.Round(fee*1e6)/1e4) / 100
+	if fee > gross {
+		fee = gross
+	}
+	quote.Fee = fee
+	quote.Net = round2(gross - fee)
+	return quote
+}
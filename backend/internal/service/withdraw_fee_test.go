@@ -0,0 +1,26 @@
+package service
+
+import "testing"
+
+func TestCalcWithdrawQuoteFeeRounding(t *testing.T) {
+	cases := []struct {
+		gross, percent, fee float64
+	}{
+		{7, 1, 0.07},
+		{14, 2, 0.28},
+		{28, 2, 0.56},
+		{57, 1.5, 0.86},
+		{10, 1.25, 0.13},
+		{0.5, 3, 0.02},
+	}
+	for _, c := range cases {
+		rules := []WithdrawFeeRule{{Method: "*", Country: "*", Currency: "*", Percent: c.percent}}
+		q := calcWithdrawQuote(rules, c.gross, "bank", "ID", "USD")
+		if q.Fee != c.fee {
+			t.Errorf("%v@%v%%: fee = %v, want %v", c.gross, c.percent, q.Fee, c.fee)
+		}
+		if q.Net != round2(c.gross-c.fee) {
+			t.Errorf("%v@%v%%: net = %v, want %v", c.gross, c.percent, q.Net, round2(c.gross-c.fee))
+		}
+	}
+}
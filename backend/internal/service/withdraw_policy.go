@@ -0,0 +1,120 @@
+package service
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"gorm.io/gorm"
+
+	"red_packet/backend/internal/models"
+)
+
+var activeWithdrawStatuses = []string{"pending", "approved", "paid"}
+
+type WithdrawPolicy struct {
+	Currency        string    `json:"currency"`
+	MinAmount       float64   `json:"min_amount"`
+	MaxAmount       float64   `json:"max_amount"`
+	DailyCountMax   int       `json:"daily_count_max"`
+	WeeklyCountMax  int       `json:"weekly_count_max"`
+	DailyAmountMax  float64   `json:"daily_amount_max"`
+	CooldownMinutes int       `json:"cooldown_minutes"`
+	AllowedAmounts  []float64 `json:"allowed_amounts"`
+	FirstAmount     float64   `json:"first_amount"`
+}
+
+func loadWithdrawPolicy(db *gorm.DB, minAmount float64) WithdrawPolicy {
+	policy := WithdrawPolicy{
+		MinAmount:       minAmount,
+		MaxAmount:       loadConfigFloat(db, "withdraw_max_amount", 0),
+		DailyCountMax:   loadConfigInt(db, "withdraw_daily_count_max", 0),
+		WeeklyCountMax:  loadConfigInt(db, "withdraw_weekly_count_max", 0),
+		DailyAmountMax:  loadConfigFloat(db, "withdraw_daily_amount_max", 0),
+		CooldownMinutes: loadConfigInt(db, "withdraw_cooldown_minutes", 0),
+		FirstAmount:     loadConfigFloat(db, "withdraw_first_amount", 0),
+	}
+	_ = jsonUnmarshal(loadConfigString(db, "withdraw_allowed_amounts", ""), &policy.AllowedAmounts)
+	return policy
+}
+
+func (p WithdrawPolicy) Check(tx *gorm.DB, userID uint, amount float64, now time.Time) error {
+	var last models.WithdrawRequest
+	hasHistory := true
+	if err := tx.Where("user_id = ? AND status IN ?", userID, activeWithdrawStatuses).
+		Order("id DESC").
+		First(&last).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		hasHistory = false
+	}
+
+	if !hasHistory && p.FirstAmount > 0 {
+		if !amountEqual(amount, p.FirstAmount) {
+			return ErrWithdrawFirstAmount
+		}
+		return nil
+	}
+
+	if amount < p.MinAmount {
+		return ErrWithdrawBelowMin
+	}
+	if p.MaxAmount > 0 && amount > p.MaxAmount {
+		return ErrWithdrawAboveMax
+	}
+	if len(p.AllowedAmounts) > 0 {
+		allowed := false
+		for _, v := range p.AllowedAmounts {
+			if amountEqual(amount, v) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return ErrWithdrawAmountNotAllowed
+		}
+	}
+
+	if !hasHistory {
+		return nil
+	}
+	if p.CooldownMinutes > 0 && now.Sub(last.CreatedAt) < time.Duration(p.CooldownMinutes)*time.Minute {
+		return ErrWithdrawCooldown
+	}
+
+	dayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	weekStart := dayStart.AddDate(0, 0, -6)
+	var history []models.WithdrawRequest
+	if err := tx.Where("user_id = ? AND status IN ? AND created_at >= ?", userID, activeWithdrawStatuses, weekStart).
+		Find(&history).Error; err != nil {
+		return err
+	}
+	var dayCount, weekCount int
+	var dayAmount float64
+	for _, h := range history {
+		if !h.CreatedAt.Before(dayStart) {
+			dayCount++
+			if p.Currency == "" || h.Currency == p.Currency {
+				dayAmount += h.Amount
+			}
+		}
+		if !h.CreatedAt.Before(weekStart) {
+			weekCount++
+		}
+	}
+	if p.DailyCountMax > 0 && dayCount >= p.DailyCountMax {
+		return ErrWithdrawDailyCount
+	}
+	if p.WeeklyCountMax > 0 && weekCount >= p.WeeklyCountMax {
+		return ErrWithdrawWeeklyCount
+	}
+	if p.DailyAmountMax > 0 && dayAmount+amount > p.DailyAmountMax {
+		return ErrWithdrawDailyAmount
+	}
+	return nil
+}
+
+func amountEqual(a, b float64) bool {
+	return math.Abs(a-b) < 0.000001
+}
@@ -7,17 +7,49 @@ const api = axios.create({
   timeout: 10000,
 });
 
+// Each submit carries an Idempotency-Key. Repeating the same request while the
+// first one is still in flight (double tap) reuses its key, so the server
+// replays or rejects it instead of applying it twice.
+const inflightKeys = new Map();
+
+function newIdempotencyKey() {
+  if (globalThis.crypto?.randomUUID) {
+    return globalThis.crypto.randomUUID();
+  }
+  return `${Date.now().toString(36)}-${Math.random().toString(36).slice(2)}`;
+}
+
+function releaseIdempotencyKey(config) {
+  if (config?.idempotencyFingerprint) {
+    inflightKeys.delete(config.idempotencyFingerprint);
+  }
+}
+
 api.interceptors.request.use((config) => {
   const token = localStorage.getItem("token");
   if (token) {
     config.headers.Authorization = `Bearer ${token}`;
   }
+  if (config.method === "post" && !config.headers["Idempotency-Key"]) {
+    const fingerprint = `${config.url} ${JSON.stringify(config.data ?? null)}`;
+    let key = inflightKeys.get(fingerprint);
+    if (!key) {
+      key = newIdempotencyKey();
+      inflightKeys.set(fingerprint, key);
+    }
+    config.headers["Idempotency-Key"] = key;
+    config.idempotencyFingerprint = fingerprint;
+  }
   return config;
 });
 
 api.interceptors.response.use(
-  (res) => res.data,
+  (res) => {
+    releaseIdempotencyKey(res.config);
+    return res.data;
+  },
   (error) => {
+    releaseIdempotencyKey(error?.config);
     if (error?.response?.status === 401) {
       const auth = useAuthStore(pinia);
       auth.logout();
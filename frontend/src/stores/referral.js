@@ -5,14 +5,20 @@ export const useReferralStore = defineStore("referral", {
   state: () => ({
     myCode: "",
     inviteCount: 0,
-    directInvites: [],
+    validCount: 0,
+    level2Count: 0,
+    earnedTotal: 0,
+    invitees: [],
   }),
   actions: {
     async fetchStatus() {
       const res = await api.get("/referral/status");
       this.myCode = res.data.my_code;
       this.inviteCount = res.data.invite_count;
-      this.directInvites = res.data.direct_invites || [];
+      this.validCount = res.data.valid_count;
+      this.level2Count = res.data.level2_count;
+      this.earnedTotal = res.data.earned_total;
+      this.invitees = res.data.invitees || [];
     },
     async bind(code) {
       return api.post("/referral/bind", { code });